@@ -0,0 +1,180 @@
+// Package apperrors holds the errors the handlers fail with. Handlers report
+// them with c.Error and the Problems middleware answers them as RFC 7807
+// problem documents, so every failure looks the same to the client.
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Error is a failure the client can be told about.
+type Error struct {
+	Status int
+	// Type names the kind of failure, the problem type is /problems/<Type>
+	Type   string
+	Detail string
+	// Fields tells which fields of the request were refused and why
+	Fields []FieldError
+	// Err is what caused the failure. It is logged, never shown.
+	Err error
+}
+
+// FieldError is a field of the request that was refused.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newError(status int, kind string, detail string) *Error {
+	return &Error{Status: status, Type: kind, Detail: detail}
+}
+
+func BadRequest(detail string) *Error {
+	return newError(400, "bad-request", detail)
+}
+
+func Unauthorized(detail string) *Error {
+	return newError(401, "unauthorized", detail)
+}
+
+func Forbidden(detail string) *Error {
+	return newError(403, "forbidden", detail)
+}
+
+func NotFound(detail string) *Error {
+	return newError(404, "not-found", detail)
+}
+
+func Conflict(detail string) *Error {
+	return newError(409, "conflict", detail)
+}
+
+// PreconditionFailed answers an If-Match naming a version the resource
+// moved on from.
+func PreconditionFailed(detail string) *Error {
+	return newError(412, "precondition-failed", detail)
+}
+
+func TooLarge(detail string) *Error {
+	return newError(413, "too-large", detail)
+}
+
+func UnsupportedMediaType(detail string) *Error {
+	return newError(415, "unsupported-media-type", detail)
+}
+
+// PreconditionRequired answers an update sent without If-Match.
+func PreconditionRequired(detail string) *Error {
+	return newError(428, "precondition-required", detail)
+}
+
+func Unavailable(detail string) *Error {
+	return newError(503, "unavailable", detail)
+}
+
+// Internal hides err from the client, who only learns that the request
+// failed on our side.
+func Internal(err error) *Error {
+	internal := newError(500, "internal", "something went wrong, try again later")
+	internal.Err = err
+	return internal
+}
+
+// Validation answers a request body that could not be read or was refused,
+// telling which fields were wrong when err knows them.
+func Validation(err error) *Error {
+	invalid := newError(400, "validation", err.Error())
+
+	var fields validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &fields):
+		invalid.Detail = "the request has invalid fields"
+		for _, field := range fields {
+			invalid.Fields = append(invalid.Fields, fieldError(field))
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		invalid.Detail = "the request has invalid fields"
+		invalid.Fields = []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be a " + typeErr.Type.String(),
+		}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		invalid.Detail = "the request body is not valid JSON"
+	}
+	return invalid
+}
+
+// fieldError describes a field the validator refused. The field is named
+// by its path in the request, without the name of the struct it was read
+// into.
+func fieldError(field validator.FieldError) FieldError {
+	path := field.Namespace()
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		path = path[i+1:]
+	}
+	return FieldError{Field: path, Rule: field.Tag(), Message: ruleMessage(field)}
+}
+
+func ruleMessage(field validator.FieldError) string {
+	tag, param := field.Tag(), field.Param()
+	if strings.Contains(tag, "|") {
+		values := []string{}
+		for _, alternative := range strings.Split(tag, "|") {
+			values = append(values, strings.TrimPrefix(alternative, "eq="))
+		}
+		return "must be one of " + strings.Join(values, ", ")
+	}
+
+	switch tag {
+	case "required":
+		return "is required"
+	case "required_if":
+		return "is required when " + strings.Replace(param, " ", " is ", 1)
+	case "required_unless":
+		return "is required unless " + strings.Replace(param, " ", " is ", 1)
+	case "required_without":
+		return "is required without " + param
+	case "min":
+		return "must be at least " + param + lengthUnit(field.Kind())
+	case "max":
+		return "must be at most " + param + lengthUnit(field.Kind())
+	case "eq":
+		return "must be " + param
+	case "email":
+		return "must be an email address"
+	}
+	return fmt.Sprintf("fails the %s rule", tag)
+}
+
+// lengthUnit tells what min and max count on a field of kind.
+func lengthUnit(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return " characters long"
+	case reflect.Slice, reflect.Map:
+		return " items"
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetOrdersByType breaks the orders placed between the `from` and `to` query
+// parameters (RFC3339, default the last thirty days) down by order type, with
+// the item revenue and delivery fees each type brought in. Orders stored
+// before order types existed count as dine-in.
+func (h *Handler) GetOrdersByType(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if c.Query("from") != "" {
+		parsed, err := time.Parse(time.RFC3339, c.Query("from"))
+		if err != nil {
+			c.Error(apperrors.BadRequest("from must be an RFC3339 timestamp"))
+			return
+		}
+		from = parsed
+	}
+	if c.Query("to") != "" {
+		parsed, err := time.Parse(time.RFC3339, c.Query("to"))
+		if err != nil {
+			c.Error(apperrors.BadRequest("to must be an RFC3339 timestamp"))
+			return
+		}
+		to = parsed
+	}
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "order_date", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}},
+	}}}
+
+	// bundle components are billed through their bundle, so only the
+	// bundle line counts towards the revenue
+	itemsLookupStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "order_item"},
+		{Key: "let", Value: bson.D{{Key: "order_id", Value: "$order_id"}}},
+		{Key: "pipeline", Value: mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.D{
+				{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$order_id", "$$order_id"}}}},
+				{Key: "item_type", Value: bson.D{{Key: "$ne", Value: models.OrderItemTypeBundleComponent}}},
+				approvedItems,
+			}}},
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "food"},
+				{Key: "localField", Value: "food_id"},
+				{Key: "foreignField", Value: "food_id"},
+				{Key: "as", Value: "food"},
+			}}},
+			bson.D{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$food"},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}},
+			bson.D{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "amount", Value: bson.D{{Key: "$multiply", Value: bson.A{
+					bson.D{{Key: "$ifNull", Value: bson.A{
+						"$unit_price",
+						bson.D{{Key: "$add", Value: bson.A{
+							bson.D{{Key: "$ifNull", Value: bson.A{"$food.price", 0}}},
+							bson.D{{Key: "$ifNull", Value: bson.A{"$modifiers_price", 0}}},
+						}}},
+					}}},
+					bson.D{{Key: "$cond", Value: bson.A{
+						bson.D{{Key: "$isNumber", Value: "$quantity"}}, "$quantity", 1,
+					}}},
+				}}}},
+			}}},
+		}},
+		{Key: "as", Value: "items"},
+	}}}
+	orderStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "order_type", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$order_type", models.OrderTypeDineIn}}}},
+		{Key: "item_revenue", Value: bson.D{{Key: "$sum", Value: "$items.amount"}}},
+		{Key: "delivery_fee", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$delivery_fee", 0}}}},
+	}}}
+	groupStage := bson.D{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: "$order_type"},
+		{Key: "order_count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		{Key: "item_revenue", Value: bson.D{{Key: "$sum", Value: "$item_revenue"}}},
+		{Key: "delivery_fees", Value: bson.D{{Key: "$sum", Value: "$delivery_fee"}}},
+	}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "order_type", Value: "$_id"},
+		{Key: "order_count", Value: 1},
+		{Key: "item_revenue", Value: bson.D{{Key: "$round", Value: bson.A{"$item_revenue", 2}}}},
+		{Key: "delivery_fees", Value: bson.D{{Key: "$round", Value: bson.A{"$delivery_fees", 2}}}},
+		{Key: "average_order_value", Value: bson.D{{Key: "$round", Value: bson.A{
+			bson.D{{Key: "$divide", Value: bson.A{
+				bson.D{{Key: "$add", Value: bson.A{"$item_revenue", "$delivery_fees"}}},
+				"$order_count",
+			}}},
+			2,
+		}}}},
+	}}}
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "order_type", Value: 1}}}}
+
+	cursor, err := h.Orders.Aggregate(ctx, mongo.Pipeline{
+		matchStage,
+		itemsLookupStage,
+		orderStage,
+		groupStage,
+		projectStage,
+		sortStage,
+	})
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the order analytics: %w", err)))
+		return
+	}
+
+	byType := []primitive.M{}
+	if err := cursor.All(ctx, &byType); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the order analytics: %w", err)))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"from":    from,
+			"to":      to,
+			"by_type": byType,
+		},
+	})
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (h *Handler) GetBundles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	query, ok := listQuery(c, bundleListFields, "name")
+	if !ok {
+		return
+	}
+
+	list(ctx, c, h.Bundles, query)
+}
+
+func (h *Handler) GetBundle(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	bundleId := c.Param("id")
+	bundle := models.Bundle{}
+
+	if err := h.Bundles.FindByID(ctx, bundleId, &bundle); err != nil {
+		c.Error(apperrors.NotFound("bundle not found"))
+		return
+	}
+
+	setETag(c, bundle.Version)
+	c.JSON(200, gin.H{"status": "success", "data": bundle})
+}
+
+func (h *Handler) CreateBundle(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	bundle := models.Bundle{}
+	menu := models.Menu{}
+
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := validate.Struct(bundle); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := h.Menus.FindByID(ctx, *bundle.MenuId, &menu); err != nil || menu.DeletedAt != nil {
+		c.Error(apperrors.NotFound("menu not found"))
+		return
+	}
+
+	if err := h.checkBundleFoods(ctx, bundle.Slots); err != nil {
+		c.Error(err)
+		return
+	}
+	helpers.PrepareBundleSlots(bundle.Slots)
+
+	price := toFixed(*bundle.Price, 2)
+	bundle.Price = &price
+	bundle.ID = primitive.NewObjectID()
+	bundle.BundleId = bundle.ID.Hex()
+	bundle.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	bundle.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if _, err := h.Bundles.InsertOne(ctx, bundle); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("failed to create bundle: %w", err)))
+		return
+	}
+
+	c.JSON(201, gin.H{"status": "success", "data": bundle})
+}
+
+func (h *Handler) UpdateBundle(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	bundleId := c.Param("id")
+	bundle := models.Bundle{}
+	menu := models.Menu{}
+
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	current := models.Bundle{}
+	if err := h.Bundles.FindByID(ctx, bundleId, &current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("bundle not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
+
+	bundleObj := primitive.D{}
+
+	if bundle.Name != nil {
+		if err := validate.Var(*bundle.Name, "min=2,max=40"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		bundleObj = append(bundleObj, bson.E{Key: "name", Value: bundle.Name})
+	}
+	if bundle.Price != nil {
+		price := toFixed(*bundle.Price, 2)
+		if price < 0 {
+			c.Error(apperrors.BadRequest("price cannot be negative"))
+			return
+		}
+		bundleObj = append(bundleObj, bson.E{Key: "price", Value: price})
+	}
+	if bundle.MenuId != nil {
+		if err := h.Menus.FindByID(ctx, *bundle.MenuId, &menu); err != nil || menu.DeletedAt != nil {
+			c.Error(apperrors.NotFound("menu not found"))
+			return
+		}
+		bundleObj = append(bundleObj, bson.E{Key: "menu_id", Value: menu.MenuId})
+	}
+	if bundle.Slots != nil {
+		if err := validate.Var(bundle.Slots, "min=1"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		for _, slot := range bundle.Slots {
+			if err := validate.Struct(slot); err != nil {
+				c.Error(apperrors.Validation(err))
+				return
+			}
+		}
+		if err := h.checkBundleFoods(ctx, bundle.Slots); err != nil {
+			c.Error(err)
+			return
+		}
+		helpers.PrepareBundleSlots(bundle.Slots)
+		bundleObj = append(bundleObj, bson.E{Key: "slots", Value: bundle.Slots})
+	}
+
+	bundle.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	bundleObj = append(bundleObj, bson.E{Key: "updated_at", Value: bundle.UpdatedAt})
+
+	filter := bson.D{{Key: "_id", Value: current.ID}, versionFilter(current.Version)}
+	result, err := h.Bundles.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bundleObj}, bumpVersion})
+	if err == nil && result.MatchedCount == 0 {
+		err = missingOrChanged(ctx, h.Bundles, current.ID)
+	}
+	if err != nil {
+		updateFailed(c, err, "bundle not found")
+		return
+	}
+	setETag(c, current.Version+1)
+
+	c.JSON(200, gin.H{"status": "success", "data": result})
+}
+
+// checkBundleFoods makes sure every food offered in the slots exists.
+func (h *Handler) checkBundleFoods(ctx context.Context, slots []models.BundleSlot) error {
+	foodIds := map[string]bool{}
+	for _, slot := range slots {
+		for _, foodId := range slot.FoodIds {
+			foodIds[foodId] = true
+		}
+	}
+
+	ids := bson.A{}
+	for foodId := range foodIds {
+		ids = append(ids, foodId)
+	}
+
+	count, err := h.Foods.CountDocuments(ctx, bson.D{{Key: "food_id", Value: bson.D{{Key: "$in", Value: ids}}}, notDeleted})
+	if err != nil {
+		return err
+	}
+	if int(count) != len(ids) {
+		return apperrors.BadRequest("bundle offers a food that does not exist")
+	}
+
+	return nil
+}
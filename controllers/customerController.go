@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (h *Handler) GetCustomers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	query, ok := listQuery(c, customerListFields, "name")
+	if !ok {
+		return
+	}
+
+	list(ctx, c, h.Customers, query)
+}
+
+func (h *Handler) GetCustomer(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	customerId := c.Param("id")
+	customer := models.Customer{}
+
+	if err := h.Customers.FindByID(ctx, customerId, &customer); err != nil {
+		c.Error(apperrors.NotFound("customer not found"))
+		return
+	}
+
+	notes, err := h.notesFor(ctx, models.NoteEntityCustomer, customer.CustomerId)
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the customer notes: %w", err)))
+		return
+	}
+	customer.Notes = notes
+
+	setETag(c, customer.Version)
+	c.JSON(200, gin.H{"status": "success", "data": customer})
+}
+
+func (h *Handler) CreateCustomer(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	customer := models.Customer{}
+	if err := c.ShouldBindJSON(&customer); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := validate.Struct(customer); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	// points and spend are only ever earned through paid invoices
+	customer.LoyaltyPoints = 0
+	customer.LifetimeSpend = 0
+	customer.LastVisitAt = nil
+	customer.ID = primitive.NewObjectID()
+	customer.CustomerId = customer.ID.Hex()
+	customer.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	customer.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if _, err := h.Customers.InsertOne(ctx, customer); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("failed to create customer: %w", err)))
+		return
+	}
+
+	c.JSON(201, gin.H{"status": "success", "data": customer})
+}
+
+func (h *Handler) UpdateCustomer(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	customerId := c.Param("id")
+	customer := models.Customer{}
+
+	if err := c.ShouldBindJSON(&customer); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	current := models.Customer{}
+	if err := h.Customers.FindByID(ctx, customerId, &current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("customer not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
+
+	customerObj := primitive.D{}
+
+	if customer.Name != nil {
+		if err := validate.Var(*customer.Name, "min=2,max=60"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		customerObj = append(customerObj, bson.E{Key: "name", Value: customer.Name})
+	}
+	if customer.Phone != nil {
+		if err := validate.Var(*customer.Phone, "min=6,max=20"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		customerObj = append(customerObj, bson.E{Key: "phone", Value: customer.Phone})
+	}
+	if customer.Email != nil {
+		if err := validate.Var(*customer.Email, "email"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		customerObj = append(customerObj, bson.E{Key: "email", Value: customer.Email})
+	}
+	if customer.Allergies != nil {
+		customerObj = append(customerObj, bson.E{Key: "allergies", Value: customer.Allergies})
+	}
+	if customer.Preferences != nil {
+		customerObj = append(customerObj, bson.E{Key: "preferences", Value: customer.Preferences})
+	}
+
+	customer.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	customerObj = append(customerObj, bson.E{Key: "updated_at", Value: customer.UpdatedAt})
+
+	filter := bson.D{{Key: "_id", Value: current.ID}, versionFilter(current.Version)}
+	result, err := h.Customers.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: customerObj}, bumpVersion})
+	if err == nil && result.MatchedCount == 0 {
+		err = missingOrChanged(ctx, h.Customers, current.ID)
+	}
+	if err != nil {
+		updateFailed(c, err, "customer not found")
+		return
+	}
+	setETag(c, current.Version+1)
+
+	c.JSON(200, gin.H{"status": "success", "data": result})
+}
+
+// GetCustomerVisits lists the customer's orders, newest first, with the
+// invoices each visit was billed on.
+func (h *Handler) GetCustomerVisits(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	customerId := c.Param("id")
+	customer := models.Customer{}
+
+	if err := h.Customers.FindByID(ctx, customerId, &customer); err != nil {
+		c.Error(apperrors.NotFound("customer not found"))
+		return
+	}
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "customer_id", Value: customerId}}}}
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "order_date", Value: -1}}}}
+	lookupInvoiceStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "invoice"},
+		{Key: "localField", Value: "order_id"},
+		{Key: "foreignField", Value: "order_id"},
+		{Key: "as", Value: "invoices"},
+	}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "order_id", Value: 1},
+		{Key: "order_date", Value: 1},
+		{Key: "table_id", Value: 1},
+		{Key: "invoices", Value: bson.D{{Key: "$map", Value: bson.D{
+			{Key: "input", Value: "$invoices"},
+			{Key: "as", Value: "invoice"},
+			{Key: "in", Value: bson.D{
+				{Key: "invoice_id", Value: "$$invoice.invoice_id"},
+				{Key: "invoice_number", Value: "$$invoice.invoice_number"},
+				{Key: "payment_status", Value: "$$invoice.payment_status"},
+				{Key: "amount_paid", Value: "$$invoice.amount_paid"},
+			}},
+		}}}},
+	}}}
+
+	cursor, err := h.Orders.Aggregate(ctx, mongo.Pipeline{matchStage, sortStage, lookupInvoiceStage, projectStage})
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the customer visits: %w", err)))
+		return
+	}
+
+	visits := []primitive.M{}
+	if err := cursor.All(ctx, &visits); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the customer visits: %w", err)))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": gin.H{
+		"visit_count":    len(visits),
+		"lifetime_spend": customer.LifetimeSpend,
+		"last_visit_at":  customer.LastVisitAt,
+		"visits":         visits,
+	}})
+}
+
+// GetCustomerLoyalty shows the customer's points balance and the ledger of
+// points earned and redeemed.
+func (h *Handler) GetCustomerLoyalty(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	customerId := c.Param("id")
+	customer := models.Customer{}
+
+	if err := h.Customers.FindByID(ctx, customerId, &customer); err != nil {
+		c.Error(apperrors.NotFound("customer not found"))
+		return
+	}
+
+	opt := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := h.LoyaltyTransactions.Find(ctx, bson.D{{Key: "customer_id", Value: customerId}}, opt)
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the loyalty ledger: %w", err)))
+		return
+	}
+
+	transactions := []models.LoyaltyTransaction{}
+	if err := cursor.All(ctx, &transactions); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the loyalty ledger: %w", err)))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": gin.H{
+		"loyalty_points": customer.LoyaltyPoints,
+		"points_value":   helpers.PointsValue(customer.LoyaltyPoints),
+		"transactions":   transactions,
+	}})
+}
+
+// checkCustomer makes sure the customer an order is placed for exists.
+func (h *Handler) checkCustomer(ctx context.Context, customerId *string) error {
+	if customerId == nil {
+		return nil
+	}
+
+	count, err := h.Customers.CountDocuments(ctx, bson.D{{Key: "customer_id", Value: customerId}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperrors.BadRequest("customer not found")
+	}
+	return nil
+}
+
+// recordVisit stamps the customer's last visit when an order is placed for
+// them.
+func (h *Handler) recordVisit(ctx context.Context, customerId *string, visitedAt time.Time) error {
+	if customerId == nil {
+		return nil
+	}
+
+	filter := bson.D{{Key: "customer_id", Value: customerId}}
+	update := bson.D{{Key: "$max", Value: bson.D{{Key: "last_visit_at", Value: visitedAt}}}, bumpVersion}
+	_, err := h.Customers.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// redeemLoyaltyPoints takes points off the customer's balance. The balance
+// check is part of the update filter, so two settlements running at the same
+// time can never spend the same points twice. The points only go when their
+// ledger row is written too.
+func (h *Handler) redeemLoyaltyPoints(ctx context.Context, customerId string, invoiceId string, points int) error {
+	filter := bson.D{
+		{Key: "customer_id", Value: customerId},
+		{Key: "loyalty_points", Value: bson.D{{Key: "$gte", Value: points}}},
+	}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: -points}, {Key: "version", Value: int64(1)}}}}
+
+	return h.Transactions.WithTransaction(ctx, func(sessCtx context.Context) error {
+		result, err := h.Customers.UpdateOne(sessCtx, filter, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return apperrors.Conflict("customer does not have enough loyalty points")
+		}
+
+		if err := h.logLoyalty(sessCtx, customerId, invoiceId, models.LoyaltyRedeem, -points); err != nil {
+			// without a transaction the points are gone already
+			if !h.Transactions.SupportsTransactions(sessCtx) {
+				giveBack := bson.D{{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: points}, {Key: "version", Value: int64(1)}}}}
+				if _, undoErr := h.Customers.UpdateOne(ctx, bson.D{{Key: "customer_id", Value: customerId}}, giveBack); undoErr != nil {
+					log.Printf("cannot give back %d points to customer %s: %v", points, customerId, undoErr)
+				}
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// refundLoyaltyPoints gives back points redeemed for a payment that could
+// not be recorded.
+func (h *Handler) refundLoyaltyPoints(ctx context.Context, customerId string, invoiceId string, points int) error {
+	filter := bson.D{{Key: "customer_id", Value: customerId}}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: points}, {Key: "version", Value: int64(1)}}}}
+
+	if _, err := h.Customers.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+	return h.logLoyalty(ctx, customerId, invoiceId, models.LoyaltyRefund, points)
+}
+
+// awardLoyalty credits the customer of a paid invoice with points for what
+// was paid in money and adds the invoice to their lifetime spend. The invoice
+// is flagged first, so an invoice earns points only once however often it is
+// marked paid.
+func (h *Handler) awardLoyalty(ctx context.Context, invoice models.Invoice, total float64) error {
+	order := models.Order{}
+	if err := h.Orders.FindByID(ctx, invoice.OrderId, &order); err != nil {
+		return err
+	}
+	if order.CustomerId == nil {
+		return nil
+	}
+
+	result, err := h.Invoices.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: invoice.ID}, {Key: "loyalty_awarded", Value: bson.D{{Key: "$ne", Value: true}}}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "loyalty_awarded", Value: true}}}, bumpVersion},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return err
+	}
+
+	paidInMoney := total
+	for _, payment := range invoice.Payments {
+		if payment.Method == "LOYALTY" {
+			paidInMoney -= payment.Amount
+		}
+	}
+	points := helpers.PointsEarned(paidInMoney)
+
+	filter := bson.D{{Key: "customer_id", Value: order.CustomerId}}
+	update := bson.D{{Key: "$inc", Value: bson.D{
+		{Key: "loyalty_points", Value: points},
+		{Key: "lifetime_spend", Value: helpers.RoundPrice(total)},
+		{Key: "version", Value: int64(1)},
+	}}}
+	if _, err := h.Customers.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	if points == 0 {
+		return nil
+	}
+	return h.logLoyalty(ctx, *order.CustomerId, invoice.InvoiceId, models.LoyaltyEarn, points)
+}
+
+func (h *Handler) logLoyalty(ctx context.Context, customerId string, invoiceId string, transactionType string, points int) error {
+	transaction := models.LoyaltyTransaction{
+		ID:         primitive.NewObjectID(),
+		CustomerId: customerId,
+		InvoiceId:  invoiceId,
+		Type:       transactionType,
+		Points:     points,
+	}
+	transaction.TransactionId = transaction.ID.Hex()
+	transaction.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	_, err := h.LoyaltyTransactions.InsertOne(ctx, transaction)
+	return err
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (h *Handler) DeleteFood(c *gin.Context) {
+	softDelete(c, h.Foods, bson.D{{Key: "food_id", Value: c.Param("id")}}, "food not found")
+}
+
+func (h *Handler) RestoreFood(c *gin.Context) {
+	restore(c, h.Foods, bson.D{{Key: "food_id", Value: c.Param("id")}}, "food not found")
+}
+
+func (h *Handler) DeleteMenu(c *gin.Context) {
+	softDelete(c, h.Menus, bson.D{{Key: "menu_id", Value: c.Param("id")}}, "menu not found")
+}
+
+func (h *Handler) RestoreMenu(c *gin.Context) {
+	restore(c, h.Menus, bson.D{{Key: "menu_id", Value: c.Param("id")}}, "menu not found")
+}
+
+func (h *Handler) DeleteTable(c *gin.Context) {
+	softDelete(c, h.Tables, bson.D{{Key: "table_id", Value: c.Param("id")}}, "table not found")
+}
+
+func (h *Handler) RestoreTable(c *gin.Context) {
+	restore(c, h.Tables, bson.D{{Key: "table_id", Value: c.Param("id")}}, "table not found")
+}
+
+func (h *Handler) DeleteOrder(c *gin.Context) {
+	softDelete(c, h.Orders, bson.D{{Key: "order_id", Value: c.Param("id")}}, "order not found")
+}
+
+func (h *Handler) RestoreOrder(c *gin.Context) {
+	restore(c, h.Orders, bson.D{{Key: "order_id", Value: c.Param("id")}}, "order not found")
+}
+
+func (h *Handler) DeleteInvoice(c *gin.Context) {
+	softDelete(c, h.Invoices, invoiceFilter(c.Param("id")), "invoice not found")
+}
+
+func (h *Handler) RestoreInvoice(c *gin.Context) {
+	restore(c, h.Invoices, invoiceFilter(c.Param("id")), "invoice not found")
+}
+
+// softDelete stamps the record with when and by whom it was deleted. Foods,
+// menus, tables, orders and invoices are only ever soft deleted by the API,
+// order items and invoices join on them, so a deleted record stays in its
+// collection, hidden from listings, until PurgeDeleted removes it for good.
+func softDelete(c *gin.Context, collection repositories.Collection, filter bson.D, notFound string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "deleted_at", Value: now},
+		{Key: "deleted_by", Value: signedInUser(c)},
+		{Key: "updated_at", Value: now},
+	}}, bumpVersion}
+
+	setDeleted(ctx, c, collection, filter, bson.E{Key: "deleted_at", Value: nil}, update, notFound, "already deleted")
+}
+
+// restore brings a soft deleted record back.
+func restore(c *gin.Context, collection repositories.Collection, filter bson.D, notFound string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.D{
+		{Key: "$unset", Value: bson.D{{Key: "deleted_at", Value: ""}, {Key: "deleted_by", Value: ""}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}},
+		bumpVersion,
+	}
+
+	setDeleted(ctx, c, collection, filter, bson.E{Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}}, update, notFound, "not deleted")
+}
+
+// setDeleted applies update to the record when it is in the state from
+// expects, telling a missing record apart from one already in the other
+// state.
+func setDeleted(ctx context.Context, c *gin.Context, collection repositories.Collection, filter bson.D, from bson.E, update bson.D, notFound string, conflict string) {
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	stateFilter := append(append(bson.D{}, filter...), from)
+
+	updated := bson.M{}
+	err := collection.FindOneAndUpdate(ctx, stateFilter, update, opt).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		count, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			c.Error(apperrors.Internal(err))
+			return
+		}
+		if count == 0 {
+			c.Error(apperrors.NotFound(notFound))
+			return
+		}
+		c.Error(apperrors.Conflict(conflict))
+		return
+	}
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	if version, ok := updated["version"].(int64); ok {
+		setETag(c, version)
+	}
+	c.JSON(200, gin.H{"status": "success", "data": updated})
+}
+
+// notDeleted matches the records that were not soft deleted.
+var notDeleted = bson.E{Key: "deleted_at", Value: nil}
+
+// withoutDeleted narrows a listing down to the records that were not soft
+// deleted, unless the client asks for them too with include_deleted=true.
+func withoutDeleted(c *gin.Context, filter bson.D) bson.D {
+	if c.Query("include_deleted") == "true" {
+		return filter
+	}
+	return append(filter, notDeleted)
+}
+
+type reference struct {
+	collection repositories.Collection
+	field      string
+	filter     bson.D
+}
+
+type purgeTarget struct {
+	name       string
+	collection repositories.Collection
+	idField    string
+	// usedBy are the records that keep a deleted record from being purged
+	// as long as they point at it, deleted or not.
+	usedBy []reference
+	// parts are purged along with the record.
+	parts []reference
+}
+
+// purgeTargets are in the order they are purged, so a record freed by an
+// earlier purge goes in the same run. Invoices are never purged: their
+// numbers have to stay without gaps and the loyalty and gift card ledgers
+// point at them.
+func (h *Handler) purgeTargets() []purgeTarget {
+	notesOf := func(entityType string) reference {
+		return reference{h.Notes, "entity_id", bson.D{{Key: "entity_type", Value: entityType}}}
+	}
+	return []purgeTarget{
+		{name: "orders", collection: h.Orders, idField: "order_id",
+			usedBy: []reference{{h.Invoices, "order_id", nil}},
+			parts:  []reference{{h.OrderItems, "order_id", nil}, notesOf(models.NoteEntityOrder)},
+		},
+		{name: "tables", collection: h.Tables, idField: "table_id",
+			usedBy: []reference{{h.Orders, "table_id", nil}},
+			parts:  []reference{notesOf(models.NoteEntityTable)},
+		},
+		{name: "foods", collection: h.Foods, idField: "food_id",
+			usedBy: []reference{{h.OrderItems, "food_id", nil}, {h.Bundles, "slots.food_ids", nil}},
+			parts:  []reference{notesOf(models.NoteEntityFood)},
+		},
+		{name: "menus", collection: h.Menus, idField: "menu_id",
+			usedBy: []reference{{h.Foods, "menu_id", nil}, {h.Bundles, "menu_id", nil}},
+		},
+	}
+}
+
+// PurgeDeleted removes the records soft deleted before the cutoff for good,
+// except those other records still point at. It returns how many went per
+// collection.
+func (h *Handler) PurgeDeleted(ctx context.Context, before time.Time) (map[string]int64, error) {
+	purged := map[string]int64{}
+	for _, target := range h.purgeTargets() {
+		filter := bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}, {Key: "$lt", Value: before}}}}
+		ids, err := target.collection.Distinct(ctx, target.idField, filter)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, ref := range target.usedBy {
+			if len(ids) == 0 {
+				break
+			}
+			refFilter := append(append(bson.D{}, ref.filter...), bson.E{Key: ref.field, Value: bson.D{{Key: "$in", Value: ids}}})
+			used, err := ref.collection.Distinct(ctx, ref.field, refFilter)
+			if err != nil {
+				return purged, err
+			}
+			ids = withoutValues(ids, used)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		for _, part := range target.parts {
+			partFilter := append(append(bson.D{}, part.filter...), bson.E{Key: part.field, Value: bson.D{{Key: "$in", Value: ids}}})
+			if _, err := part.collection.DeleteMany(ctx, partFilter); err != nil {
+				return purged, err
+			}
+		}
+
+		result, err := target.collection.DeleteMany(ctx, bson.D{{Key: target.idField, Value: bson.D{{Key: "$in", Value: ids}}}, filter[0]})
+		if err != nil {
+			return purged, err
+		}
+		purged[target.name] = result.DeletedCount
+	}
+	return purged, nil
+}
+
+func withoutValues(values []interface{}, remove []interface{}) []interface{} {
+	removed := map[interface{}]bool{}
+	for _, value := range remove {
+		removed[value] = true
+	}
+	kept := []interface{}{}
+	for _, value := range values {
+		if !removed[value] {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
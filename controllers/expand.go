@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// maxExpandDepth is how far one expansion may reach, as in
+	// order.items.food from an invoice.
+	maxExpandDepth = 3
+	maxExpansions  = 10
+)
+
+// relation joins the documents of another collection whose foreignField
+// holds the value of localField.
+type relation struct {
+	from         string
+	localField   string
+	foreignField string
+	// many relations expand to an array, the others to one document or
+	// nothing
+	many bool
+}
+
+// relations are what ?expand= can follow from the documents of each
+// collection. Soft deleted documents are expanded too, the records that
+// point at them still do.
+var relations = map[string]map[string]relation{
+	"order": {
+		"table":    {from: "table", localField: "table_id", foreignField: "table_id"},
+		"customer": {from: "customer", localField: "customer_id", foreignField: "customer_id"},
+		"items":    {from: "order_item", localField: "order_id", foreignField: "order_id", many: true},
+		"invoices": {from: "invoice", localField: "order_id", foreignField: "order_id", many: true},
+		// invoice is how clients ask for them too, an order split by seat
+		// still has several
+		"invoice": {from: "invoice", localField: "order_id", foreignField: "order_id", many: true},
+	},
+	"order_item": {
+		"order":  {from: "order", localField: "order_id", foreignField: "order_id"},
+		"food":   {from: "food", localField: "food_id", foreignField: "food_id"},
+		"bundle": {from: "bundle", localField: "bundle_id", foreignField: "bundle_id"},
+	},
+	"invoice": {
+		"order": {from: "order", localField: "order_id", foreignField: "order_id"},
+	},
+	"food": {
+		"menu":    {from: "menu", localField: "menu_id", foreignField: "menu_id"},
+		"image":   {from: "image", localField: "image_id", foreignField: "image_id"},
+		"bundles": {from: "bundle", localField: "food_id", foreignField: "slots.food_ids", many: true},
+	},
+	"bundle": {
+		"menu": {from: "menu", localField: "menu_id", foreignField: "menu_id"},
+	},
+}
+
+type expansion struct {
+	name     string
+	relation relation
+	nested   []*expansion
+}
+
+// parseExpand reads a comma separated list of dotted relation paths, such
+// as `table,items.food`, into the expansions of collection.
+func parseExpand(collection string, value string) ([]*expansion, error) {
+	expansions := []*expansion{}
+	count := 0
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		names := strings.Split(path, ".")
+		if len(names) > maxExpandDepth {
+			return nil, fmt.Errorf("expand at most %d relations deep", maxExpandDepth)
+		}
+
+		from, level := collection, &expansions
+		for _, name := range names {
+			var found *expansion
+			for _, e := range *level {
+				if e.name == name {
+					found = e
+				}
+			}
+			if found == nil {
+				related, ok := relations[from][name]
+				if !ok {
+					return nil, fmt.Errorf("cannot expand %s on %s", name, from)
+				}
+				if count++; count > maxExpansions {
+					return nil, fmt.Errorf("expand at most %d relations", maxExpansions)
+				}
+				found = &expansion{name: name, relation: related}
+				*level = append(*level, found)
+			}
+			from, level = found.relation.from, &found.nested
+		}
+	}
+	return expansions, nil
+}
+
+// lookupStages joins what the expansions name onto the documents, each
+// under the name of its relation. Nested expansions run as the pipeline of a
+// $lookup that also has localField and foreignField, which takes MongoDB 5.0
+// or later.
+func lookupStages(expansions []*expansion) []bson.D {
+	stages := []bson.D{}
+	for _, e := range expansions {
+		lookup := bson.D{
+			{Key: "from", Value: e.relation.from},
+			{Key: "localField", Value: e.relation.localField},
+			{Key: "foreignField", Value: e.relation.foreignField},
+		}
+		if nested := lookupStages(e.nested); len(nested) > 0 {
+			lookup = append(lookup, bson.E{Key: "pipeline", Value: nested})
+		}
+		lookup = append(lookup, bson.E{Key: "as", Value: e.name})
+		stages = append(stages, bson.D{{Key: "$lookup", Value: lookup}})
+
+		if !e.relation.many {
+			stages = append(stages, bson.D{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$" + e.name},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}})
+		}
+	}
+	return stages
+}
+
+// expandQuery reads ?expand= for the documents of collection into the
+// stages that expand them. It fails the request itself and tells whether the
+// handler may go on.
+func expandQuery(c *gin.Context, collection string) ([]bson.D, []string, bool) {
+	expansions, err := parseExpand(collection, c.Query("expand"))
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return nil, nil, false
+	}
+	names := make([]string, len(expansions))
+	for i, e := range expansions {
+		names[i] = e.name
+	}
+	return lookupStages(expansions), names, true
+}
+
+// expandOne adds the expansions of the document with the given _id to data,
+// the resource as the handler shows it, and keeps the ?fields= of it.
+func expandOne(ctx context.Context, c *gin.Context, collection repositories.Collection, id primitive.ObjectID, stages []bson.D, names []string, data interface{}) (interface{}, error) {
+	if len(stages) > 0 {
+		pipeline := append(mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "_id", Value: id}}}},
+		}, stages...)
+		cursor, err := collection.Aggregate(ctx, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		expanded := []primitive.M{}
+		if err := cursor.All(ctx, &expanded); err != nil {
+			return nil, err
+		}
+
+		document := map[string]interface{}{}
+		encoded, err := json.Marshal(data)
+		if err == nil {
+			err = json.Unmarshal(encoded, &document)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			document[name] = nil
+			if len(expanded) > 0 {
+				if value, ok := expanded[0][name]; ok {
+					document[name] = value
+				}
+			}
+		}
+		data = document
+	}
+
+	return helpers.SelectFields(data, c.Query("fields"))
+}
@@ -3,119 +3,145 @@ package controllers
 import (
 	"context"
 	"math"
-	"strconv"
+	"reflect"
+	"strings"
 	"time"
 
-	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
 	"github.com/RahulMj21/mongo-restaurant-management/models"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
+var validate = newValidator()
 
-var validate = validator.New()
+// newValidator names fields by their JSON name, the one the client sent.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
 
-func GetFoods(c *gin.Context) {
+func (h *Handler) GetFoods(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	resultPerPage, err := strconv.Atoi(c.Query("resultPerPage"))
-	if err != nil || resultPerPage < 1 {
-		resultPerPage = 10
+	query, ok := listQuery(c, foodListFields, "name")
+	if !ok {
+		return
 	}
-
-	page, err := strconv.Atoi(c.Query("page"))
-	if err != nil || page < 1 {
-		page = 1
+	filter, err := foodFilter(c)
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	query.Filter = withoutDeleted(c, append(query.Filter, filter...))
+	if query.PageStages, _, ok = expandQuery(c, "food"); !ok {
+		return
 	}
 
-	startIndex, err := strconv.Atoi(c.Query("startIndex"))
-	if err != nil || startIndex < 0 {
-		startIndex = (page - 1) * resultPerPage
+	chain, ok := languageChain(c)
+	if !ok {
+		return
 	}
 
-	matchStage := bson.D{{Key: "$match", Value: bson.D{{}}}}
-	groupStage := bson.D{{Key: "$group", Value: bson.D{
-		{Key: "_id", Value: bson.D{{Key: "_id", Value: "null"}}},
-		{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
-		{Key: "data", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
-	}}}
-	projectState := bson.D{{Key: "$project", Value: bson.D{
-		{Key: "_id", Value: 0},
-		{Key: "total_count", Value: 1},
-		{Key: "food_items", Value: bson.D{{Key: "$slice", Value: []interface{}{"$data", startIndex, resultPerPage}}}},
-	}}}
+	list(ctx, c, h.Foods, query, helpers.LocalizeStage(chain))
+}
 
-	result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{matchStage, groupStage, projectState})
-	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+func (h *Handler) GetFood(c *gin.Context) {
+	chain, ok := languageChain(c)
+	if !ok {
 		return
 	}
-	foods := []primitive.D{}
 
-	if err = result.All(ctx, &foods); err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+	stages, expanded, ok := expandQuery(c, "food")
+	if !ok {
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "success", "data": foods})
-}
-
-func GetFood(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	food_id := c.Param("id")
 	food := models.Food{}
-	err := foodCollection.FindOne(ctx, bson.M{"food_id": food_id}).Decode(&food)
+	err := h.Foods.FindByID(ctx, food_id, &food)
 	defer cancel()
+	if err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("food not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	localizeFood(chain, &food)
+	c.Header("Content-Language", food.Language)
+
+	notes, err := h.notesFor(ctx, models.NoteEntityFood, food_id)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "food not found"})
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	food.Notes = notes
+
+	data, err := expandOne(ctx, c, h.Foods, food.ID, stages, expanded, food)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
 	}
-	c.JSON(200, food)
+	setETag(c, food.Version)
+	c.JSON(200, gin.H{"status": "success", "data": data})
 }
 
-func CreateFood(c *gin.Context) {
+func (h *Handler) CreateFood(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 	menu := models.Menu{}
 	food := models.Food{}
 
-	if err := c.BindJSON(&food); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := c.ShouldBindJSON(&food); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
+	// food_id is required, give it before the food is checked
+	food.ID = primitive.NewObjectID()
+	food.FoodId = food.ID.Hex()
+
 	validationErr := validate.Struct(food)
 	if validationErr != nil {
-		c.JSON(400, gin.H{"error": validationErr.Error()})
+		c.Error(apperrors.Validation(validationErr))
 		return
 	}
 
-	err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.MenuId}).Decode(&menu)
+	err := h.Menus.FindByID(ctx, *food.MenuId, &menu)
 	defer cancel()
-	if err != nil {
-		c.JSON(404, gin.H{"error": "menu not found"})
+	if err != nil || menu.DeletedAt != nil {
+		c.Error(apperrors.NotFound("menu not found"))
+		return
+	}
+
+	if err := h.prepareFood(ctx, &food); err != nil {
+		c.Error(err)
 		return
 	}
 
 	food.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	food.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	food.ID = primitive.NewObjectID()
-	food.FoodId = food.ID.Hex()
-	num := toFixed(*food.Price, 2)
-	food.Price = &num
 
-	result, err := foodCollection.InsertOne(ctx, food)
-	defer cancel()
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if _, err := h.Foods.InsertOne(ctx, food); err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
-	c.JSON(200, result)
+	setETag(c, food.Version)
+	c.JSON(201, gin.H{"status": "success", "data": food})
 }
 
 func round(num float64) int {
@@ -124,67 +150,135 @@ func round(num float64) int {
 
 func toFixed(num float64, precision int) float64 {
 	output := math.Pow(10, float64(precision))
-	return float64(round(output)) / output
+	return float64(round(num*output)) / output
 }
 
-func UpdateFood(c *gin.Context) {
+// UpdateFood replaces the food on PUT and merge patches it on PATCH. Either
+// way the result is checked like a new food before it is stored.
+func (h *Handler) UpdateFood(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	menu := models.Menu{}
-	food := models.Food{}
-
+	current := models.Food{}
 	foodId := c.Param("id")
 
-	if err := c.BindJSON(&food); err != nil {
-		c.JSON(400, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	if err := h.Foods.FindByID(ctx, foodId, &current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("food not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	var foodObj primitive.D
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
 
-	if food.Name != nil {
-		foodObj = append(foodObj, bson.E{Key: "name", Value: food.Name})
+	food := models.Food{}
+	if err := readUpdate(c, current, &food); err != nil {
+		updateBodyFailed(c, err)
+		return
 	}
-	if food.Price != nil {
-		foodObj = append(foodObj, bson.E{Key: "price", Value: food.Price})
+	food.ID, food.FoodId, food.CreatedAt = current.ID, current.FoodId, current.CreatedAt
+	food.DeletedAt, food.DeletedBy = current.DeletedAt, current.DeletedBy
+	food.Language, food.Notes = "", nil
+
+	if err := validate.Struct(food); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
 	}
-	if food.FoodImage != nil {
-		foodObj = append(foodObj, bson.E{Key: "food_image", Value: food.FoodImage})
+	if err := h.Menus.FindByID(ctx, *food.MenuId, &menu); err != nil || (menu.DeletedAt != nil && !sameString(food.MenuId, current.MenuId)) {
+		c.Error(apperrors.BadRequest("menu not found"))
+		return
 	}
-	if food.MenuId != nil {
-		err := menuCollection.FindOne(ctx, bson.D{{Key: "menu_id", Value: food.MenuId}}).Decode(&menu)
-		if err != nil {
-			c.JSON(400, gin.H{
-				"status":  "fail",
-				"message": err.Error(),
-			})
-			return
-		}
-		foodObj = append(foodObj, bson.E{Key: "menu_id", Value: menu.MenuId})
+	if err := h.prepareFood(ctx, &food); err != nil {
+		c.Error(err)
+		return
 	}
 
+	food.Version = current.Version + 1
 	food.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	foodObj = append(foodObj, bson.E{Key: "updated_at", Value: food.UpdatedAt})
 
-	upsert := true
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+	if err := replaceDocument(ctx, h.Foods, food.ID, current.Version, food); err != nil {
+		updateFailed(c, err, "food not found")
+		return
 	}
-	filter := bson.D{{Key: "food_id", Value: foodId}}
+	setETag(c, food.Version)
+
+	c.JSON(200, gin.H{"status": "success", "data": food})
+}
 
-	result, err := foodCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: foodObj}}, &opt)
+// prepareFood checks what the validation tags cannot, the image, modifiers,
+// sizes, translations and dietary tags of a validated food, and normalizes
+// them for storing.
+func (h *Handler) prepareFood(ctx context.Context, food *models.Food) error {
+	if err := h.checkImage(ctx, food.ImageId); err != nil {
+		return err
+	}
+	if err := helpers.PrepareModifierGroups(food.ModifierGroups); err != nil {
+		return apperrors.Validation(err)
+	}
+	if err := helpers.PrepareSizePrices(food.SizePrices); err != nil {
+		return apperrors.Validation(err)
+	}
+	translations, err := helpers.PrepareTranslations(food.Translations)
 	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
-		return
+		return apperrors.Validation(err)
+	}
+	food.Translations = translations
+	food.Allergens = helpers.UniqueLabels(food.Allergens)
+	food.DietaryTags = helpers.UniqueLabels(food.DietaryTags)
+	if err := helpers.CheckDietaryTags(food.Allergens, food.DietaryTags); err != nil {
+		return apperrors.Validation(err)
 	}
 
-	c.JSON(200, gin.H{
-		"status": "success",
-		"data":   result,
-	})
+	price := toFixed(*food.Price, 2)
+	food.Price = &price
+	return nil
+}
 
+// foodFilter narrows food listings down to the foods carrying every dietary
+// tag in `tags` and none of the allergens in `exclude_allergens`, both comma
+// separated. Foods whose allergens were never declared are excluded too, for
+// a guest with an allergy unknown is not the same as none.
+func foodFilter(c *gin.Context) (bson.D, error) {
+	filter := bson.D{}
+
+	tags, err := helpers.ParseDietaryTags(c.Query("tags"))
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		filter = append(filter, bson.E{Key: "dietary_tags", Value: bson.D{{Key: "$all", Value: tags}}})
+	}
+
+	allergens, err := helpers.ParseAllergens(c.Query("exclude_allergens"))
+	if err != nil {
+		return nil, err
+	}
+	if len(allergens) > 0 {
+		excluded := bson.A{}
+		for _, allergen := range allergens {
+			excluded = append(excluded, allergen)
+		}
+		// a declared list, even an empty one, is an array; undeclared
+		// allergens are missing or null
+		filter = append(filter, bson.E{Key: "allergens", Value: bson.D{
+			{Key: "$type", Value: "array"},
+			{Key: "$nin", Value: excluded},
+		}})
+	}
+
+	return filter, nil
+}
+
+// localizeFood swaps the food's name and description for the ones in the
+// first language of the chain that has them.
+func localizeFood(chain []string, food *models.Food) {
+	if food.Name == nil {
+		return
+	}
+	name, description, language := helpers.Localize(chain, *food.Name, food.Description, food.Translations)
+	food.Name, food.Description, food.Language = &name, description, language
 }
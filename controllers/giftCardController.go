@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReloadBody struct {
+	Amount float64 `json:"amount" validate:"required,gt=0"`
+}
+
+func (h *Handler) GetGiftCards(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	query, ok := listQuery(c, giftCardListFields, "-created_at")
+	if !ok {
+		return
+	}
+
+	list(ctx, c, h.GiftCards, query)
+}
+
+func (h *Handler) GetGiftCard(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	giftCard := models.GiftCard{}
+	if err := h.findGiftCard(ctx, c.Param("code"), &giftCard); err != nil {
+		c.Error(apperrors.NotFound("gift card not found"))
+		return
+	}
+
+	setETag(c, giftCard.Version)
+	c.JSON(200, gin.H{"status": "success", "data": giftCard})
+}
+
+// GetGiftCardBalance is the balance check a guest asks for at the counter.
+func (h *Handler) GetGiftCardBalance(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	giftCard := models.GiftCard{}
+	if err := h.findGiftCard(ctx, c.Param("code"), &giftCard); err != nil {
+		c.Error(apperrors.NotFound("gift card not found"))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": gin.H{
+		"code":       giftCard.Code,
+		"balance":    giftCard.Balance,
+		"expires_at": giftCard.ExpiresAt,
+		"expired":    giftCardExpired(giftCard),
+		"status":     giftCard.Status,
+	}})
+}
+
+func (h *Handler) GetGiftCardTransactions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	giftCard := models.GiftCard{}
+	if err := h.findGiftCard(ctx, c.Param("code"), &giftCard); err != nil {
+		c.Error(apperrors.NotFound("gift card not found"))
+		return
+	}
+
+	opt := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := h.GiftCardTransactions.Find(ctx, bson.D{{Key: "gift_card_id", Value: giftCard.GiftCardId}}, opt)
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the gift card transactions: %w", err)))
+		return
+	}
+
+	transactions := []models.GiftCardTransaction{}
+	if err := cursor.All(ctx, &transactions); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the gift card transactions: %w", err)))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": transactions})
+}
+
+// IssueGiftCard sells a new gift card with a freshly generated code.
+func (h *Handler) IssueGiftCard(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	giftCard := models.GiftCard{}
+	if err := c.ShouldBindJSON(&giftCard); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := validate.Struct(giftCard); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if giftCard.ExpiresAt != nil && giftCard.ExpiresAt.Before(time.Now()) {
+		c.Error(apperrors.BadRequest("expires_at must be in the future"))
+		return
+	}
+
+	code, err := helpers.GenerateGiftCardCode()
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("failed to generate a gift card code: %w", err)))
+		return
+	}
+
+	initialBalance := helpers.RoundPrice(*giftCard.InitialBalance)
+	giftCard.InitialBalance = &initialBalance
+	giftCard.Balance = initialBalance
+	giftCard.Code = code
+	giftCard.Status = models.GiftCardActive
+	giftCard.ID = primitive.NewObjectID()
+	giftCard.GiftCardId = giftCard.ID.Hex()
+	giftCard.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	giftCard.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if _, err := h.GiftCards.InsertOne(ctx, giftCard); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("failed to issue gift card: %w", err)))
+		return
+	}
+
+	if err := h.logGiftCard(ctx, giftCard.GiftCardId, "", models.GiftCardIssue, initialBalance, initialBalance); err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	c.JSON(201, gin.H{"status": "success", "data": giftCard})
+}
+
+// ReloadGiftCard tops up an active gift card that has not expired.
+func (h *Handler) ReloadGiftCard(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	body := ReloadBody{}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if err := validate.Struct(body); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	amount := helpers.RoundPrice(body.Amount)
+	filter := usableGiftCardFilter(c.Param("code"))
+
+	giftCard := models.GiftCard{}
+	err := h.Transactions.WithTransaction(ctx, func(sessCtx context.Context) error {
+		var err error
+		giftCard, err = h.changeBalance(sessCtx, filter, amount)
+		if err == mongo.ErrNoDocuments {
+			return apperrors.Conflict("gift card not found or no longer usable")
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := h.logGiftCard(sessCtx, giftCard.GiftCardId, "", models.GiftCardReload, amount, giftCard.Balance); err != nil {
+			// without a transaction the balance is topped up already
+			if !h.Transactions.SupportsTransactions(sessCtx) {
+				h.undoBalance(ctx, giftCard.GiftCardId, -amount)
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": giftCard})
+}
+
+func (h *Handler) findGiftCard(ctx context.Context, code string, giftCard *models.GiftCard) error {
+	filter := bson.D{{Key: "code", Value: helpers.NormalizeGiftCardCode(code)}}
+	return h.GiftCards.FindOne(ctx, filter).Decode(giftCard)
+}
+
+func giftCardExpired(giftCard models.GiftCard) bool {
+	return giftCard.ExpiresAt != nil && giftCard.ExpiresAt.Before(time.Now())
+}
+
+// usableGiftCardFilter matches the card only while it is active and has not
+// expired.
+func usableGiftCardFilter(code string) bson.D {
+	return bson.D{
+		{Key: "code", Value: helpers.NormalizeGiftCardCode(code)},
+		{Key: "status", Value: models.GiftCardActive},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "expires_at", Value: nil}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}}},
+		}},
+	}
+}
+
+// redeemGiftCard takes amount off the card's balance. The balance check is
+// part of the update filter, so two payments made with the same card at the
+// same time can never overdraw it. The balance only goes down when the ledger
+// row is written too.
+func (h *Handler) redeemGiftCard(ctx context.Context, code string, invoiceId string, amount float64) (models.GiftCard, error) {
+	filter := append(usableGiftCardFilter(code), bson.E{Key: "balance", Value: bson.D{{Key: "$gte", Value: amount}}})
+
+	giftCard := models.GiftCard{}
+	err := h.Transactions.WithTransaction(ctx, func(sessCtx context.Context) error {
+		var err error
+		giftCard, err = h.changeBalance(sessCtx, filter, -amount)
+		if err == mongo.ErrNoDocuments {
+			return apperrors.Conflict("gift card not found, expired or without enough balance")
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := h.logGiftCard(sessCtx, giftCard.GiftCardId, invoiceId, models.GiftCardRedeem, -amount, giftCard.Balance); err != nil {
+			// without a transaction the balance is taken already
+			if !h.Transactions.SupportsTransactions(sessCtx) {
+				h.undoBalance(ctx, giftCard.GiftCardId, amount)
+			}
+			return err
+		}
+		return nil
+	})
+	return giftCard, err
+}
+
+// refundGiftCard gives back an amount redeemed for a payment that could not
+// be recorded.
+func (h *Handler) refundGiftCard(ctx context.Context, giftCardId string, invoiceId string, amount float64) error {
+	filter := bson.D{{Key: "gift_card_id", Value: giftCardId}}
+
+	giftCard, err := h.changeBalance(ctx, filter, amount)
+	if err != nil {
+		return err
+	}
+	return h.logGiftCard(ctx, giftCardId, invoiceId, models.GiftCardRefund, amount, giftCard.Balance)
+}
+
+// changeBalance adds amount, or takes it off when negative, to the balance of
+// the card filter matches and returns the card as it is after. The balance is
+// rounded to the cent in the same update, so it never drifts off what the
+// ledger adds up to and a balance of 10.00 always covers 10.00.
+func (h *Handler) changeBalance(ctx context.Context, filter bson.D, amount float64) (models.GiftCard, error) {
+	balance := bson.D{{Key: "$add", Value: bson.A{"$balance", amount}}}
+	version := bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$version", int64(0)}}}, int64(1)}}}
+	update := bson.A{bson.D{{Key: "$set", Value: bson.D{
+		{Key: "balance", Value: bson.D{{Key: "$round", Value: bson.A{balance, 2}}}},
+		{Key: "version", Value: version},
+		{Key: "updated_at", Value: time.Now()},
+	}}}}
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	giftCard := models.GiftCard{}
+	err := h.GiftCards.FindOneAndUpdate(ctx, filter, update, opt).Decode(&giftCard)
+	return giftCard, err
+}
+
+// undoBalance takes back a balance change whose ledger row could not be
+// written. There is nobody left to tell when that fails too, so it is logged
+// for someone to put right by hand.
+func (h *Handler) undoBalance(ctx context.Context, giftCardId string, amount float64) {
+	if _, err := h.changeBalance(ctx, bson.D{{Key: "gift_card_id", Value: giftCardId}}, amount); err != nil {
+		log.Printf("cannot change the balance of gift card %s back by %.2f: %v", giftCardId, amount, err)
+	}
+}
+
+func (h *Handler) logGiftCard(ctx context.Context, giftCardId string, invoiceId string, transactionType string, amount float64, balanceAfter float64) error {
+	transaction := models.GiftCardTransaction{
+		ID:           primitive.NewObjectID(),
+		GiftCardId:   giftCardId,
+		InvoiceId:    invoiceId,
+		Type:         transactionType,
+		Amount:       amount,
+		BalanceAfter: helpers.RoundPrice(balanceAfter),
+	}
+	transaction.TransactionId = transaction.ID.Hex()
+	transaction.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	_, err := h.GiftCardTransactions.InsertOne(ctx, transaction)
+	return err
+}
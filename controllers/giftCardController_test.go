@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func giftCardRoutes(app *gin.Engine, h *Handler) {
+	app.GET("/gift-cards/:code/balance", h.GetGiftCardBalance)
+	app.POST("/gift-cards", h.IssueGiftCard)
+	app.POST("/gift-cards/:code/reload", h.ReloadGiftCard)
+}
+
+func TestReloadGiftCardKeepsCents(t *testing.T) {
+	app, h := newTestApp(giftCardRoutes)
+
+	issued := models.GiftCard{}
+	expectStatus(t, serve(app, http.MethodPost, "/gift-cards", `{"initial_balance":0.1}`), http.StatusCreated, &issued)
+
+	reloaded := models.GiftCard{}
+	expectStatus(t, serve(app, http.MethodPost, "/gift-cards/"+issued.Code+"/reload", `{"amount":0.2}`), http.StatusOK, &reloaded)
+	if reloaded.Balance != 0.3 || reloaded.Version != 1 {
+		t.Errorf("reloaded card holds %v at version %d, want 0.3 at version 1", reloaded.Balance, reloaded.Version)
+	}
+
+	// 0.1 and 0.2 make 0.30000000000000004 in floats, a rounded balance
+	// covers exactly 0.3
+	redeemed, err := h.redeemGiftCard(testContext(t), issued.Code, "", 0.3)
+	if err != nil {
+		t.Fatalf("cannot redeem the whole balance: %v", err)
+	}
+	if redeemed.Balance != 0 {
+		t.Errorf("balance %v left after redeeming all of it", redeemed.Balance)
+	}
+
+	balance := struct {
+		Balance float64 `json:"balance"`
+	}{}
+	expectStatus(t, serve(app, http.MethodGet, "/gift-cards/"+issued.Code+"/balance", ""), http.StatusOK, &balance)
+	if balance.Balance != 0 {
+		t.Errorf("balance check shows %v, want 0", balance.Balance)
+	}
+
+	if _, err := h.redeemGiftCard(testContext(t), issued.Code, "", 0.01); err == nil {
+		t.Error("redeemed more than the card holds")
+	}
+}
+
+func TestReloadGiftCardNotFound(t *testing.T) {
+	app, _ := newTestApp(giftCardRoutes)
+
+	expectStatus(t, serve(app, http.MethodPost, "/gift-cards/missing/reload", `{"amount":5}`), http.StatusConflict, nil)
+	expectStatus(t, serve(app, http.MethodGet, "/gift-cards/missing/balance", ""), http.StatusNotFound, nil)
+}
@@ -0,0 +1,429 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Guests order from the link on their table's QR code without logging in.
+// The signed token in the link is all they have, so every guest handler is
+// limited to that table's open order and to the menus active right now.
+
+const maxGuestOrderItems = 20
+
+type GuestOrderPack struct {
+	OrderItems []models.OrderItem `json:"order_items"`
+}
+
+// GetGuestTable tells the guest which table the link belongs to and which
+// order their items will be added to.
+func (h *Handler) GetGuestTable(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	table, ok := h.guestTable(ctx, c)
+	if !ok {
+		return
+	}
+
+	order, err := h.openTableOrder(ctx, table.TableId)
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the table's order: %w", err)))
+		return
+	}
+	var orderId *string
+	if order != nil {
+		orderId = &order.OrderId
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": gin.H{
+		"table_number":     table.TableNumber,
+		"number_of_guests": table.NumberOfGuests,
+		"order_id":         orderId,
+	}})
+}
+
+// GetGuestMenu lists the active menus with their foods, sizes, modifiers and
+// allergens in the guest's language. Guests filter it with `tags` and
+// `exclude_allergens` like staff filter GetFoods.
+func (h *Handler) GetGuestMenu(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if _, ok := h.guestTable(ctx, c); !ok {
+		return
+	}
+	chain, ok := languageChain(c)
+	if !ok {
+		return
+	}
+
+	filter, err := foodFilter(c)
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	foodMatch := bson.D{{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$menu_id", "$$menu_id"}}}}}
+	foodMatch = append(append(foodMatch, filter...), notDeleted)
+
+	matchStage := bson.D{{Key: "$match", Value: activeMenuFilter(time.Now())}}
+	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "food"},
+		{Key: "let", Value: bson.D{{Key: "menu_id", Value: "$menu_id"}}},
+		{Key: "pipeline", Value: mongo.Pipeline{
+			bson.D{{Key: "$match", Value: foodMatch}},
+			helpers.LocalizeStage(chain),
+		}},
+		{Key: "as", Value: "foods"},
+	}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "menu_id", Value: 1},
+		{Key: "name", Value: 1},
+		{Key: "description", Value: 1},
+		{Key: "language", Value: 1},
+		{Key: "category", Value: 1},
+		{Key: "foods.food_id", Value: 1},
+		{Key: "foods.name", Value: 1},
+		{Key: "foods.description", Value: 1},
+		{Key: "foods.language", Value: 1},
+		{Key: "foods.price", Value: 1},
+		{Key: "foods.food_image", Value: 1},
+		{Key: "foods.image_id", Value: 1},
+		{Key: "foods.size_prices", Value: 1},
+		{Key: "foods.modifier_groups", Value: 1},
+		{Key: "foods.allergens", Value: 1},
+		{Key: "foods.dietary_tags", Value: 1},
+		{Key: "foods.nutrition", Value: 1},
+	}}}
+
+	cursor, err := h.Menus.Aggregate(ctx, mongo.Pipeline{matchStage, helpers.LocalizeStage(chain), lookupStage, projectStage})
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the menu: %w", err)))
+		return
+	}
+
+	menus := []primitive.M{}
+	if err := cursor.All(ctx, &menus); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the menu: %w", err)))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": menus})
+}
+
+// GetGuestOrder shows everything ordered on the table's open order, with the
+// approval status of the items the guests ordered themselves.
+func (h *Handler) GetGuestOrder(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	table, ok := h.guestTable(ctx, c)
+	if !ok {
+		return
+	}
+
+	order, err := h.openTableOrder(ctx, table.TableId)
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the table's order: %w", err)))
+		return
+	}
+	if order == nil {
+		c.JSON(200, gin.H{"status": "success", "data": []primitive.M{}})
+		return
+	}
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "order_id", Value: order.OrderId},
+		{Key: "item_type", Value: bson.D{{Key: "$ne", Value: models.OrderItemTypeBundleComponent}}},
+	}}}
+	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "food"},
+		{Key: "localField", Value: "food_id"},
+		{Key: "foreignField", Value: "food_id"},
+		{Key: "as", Value: "food"},
+	}}}
+	unwindStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$food"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "order_item_id", Value: 1},
+		{Key: "food_name", Value: "$food.name"},
+		{Key: "portion_size", Value: 1},
+		{Key: "quantity", Value: 1},
+		{Key: "unit_price", Value: 1},
+		{Key: "modifiers", Value: 1},
+		{Key: "special_instructions", Value: 1},
+		{Key: "course", Value: 1},
+		{Key: "seat_number", Value: 1},
+		{Key: "approval_status", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$approval_status", models.ApprovalApproved}}}},
+		{Key: "created_at", Value: 1},
+	}}}
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}}}}
+
+	cursor, err := h.OrderItems.Aggregate(ctx, mongo.Pipeline{matchStage, lookupStage, unwindStage, projectStage, sortStage})
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the order items: %w", err)))
+		return
+	}
+
+	orderItems := []primitive.M{}
+	if err := cursor.All(ctx, &orderItems); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the order items: %w", err)))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": gin.H{
+		"order_id":    order.OrderId,
+		"order_items": orderItems,
+	}})
+}
+
+// CreateGuestOrderItems adds the guest's items to the table's open order, or
+// opens a dine-in order for the table. The items wait as PENDING until a
+// waiter approves them, only then do they reach the kitchen and the bill.
+func (h *Handler) CreateGuestOrderItems(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	table, ok := h.guestTable(ctx, c)
+	if !ok {
+		return
+	}
+
+	pack := GuestOrderPack{}
+	if err := c.ShouldBindJSON(&pack); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if len(pack.OrderItems) == 0 || len(pack.OrderItems) > maxGuestOrderItems {
+		c.Error(apperrors.BadRequest("order between 1 and 20 items at a time"))
+		return
+	}
+
+	menuIds, err := h.activeMenuIds(ctx)
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the menu: %w", err)))
+		return
+	}
+
+	orderItems := []models.OrderItem{}
+	seats := []int{}
+	for _, requested := range pack.OrderItems {
+		// guests only choose what to eat, everything else is set here
+		orderItem := models.OrderItem{
+			FoodId:              requested.FoodId,
+			PortionSize:         requested.PortionSize,
+			Quantity:            requested.Quantity,
+			Modifiers:           requested.Modifiers,
+			SpecialInstructions: requested.SpecialInstructions,
+			Course:              requested.Course,
+			SeatNumber:          requested.SeatNumber,
+		}
+		if err := validate.StructExcept(orderItem, "OrderId"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+
+		onMenu, err := h.Foods.CountDocuments(ctx, bson.D{
+			{Key: "food_id", Value: orderItem.FoodId},
+			{Key: "menu_id", Value: bson.D{{Key: "$in", Value: menuIds}}},
+			notDeleted,
+		})
+		if err != nil {
+			c.Error(apperrors.Internal(err))
+			return
+		}
+		if onMenu == 0 {
+			c.Error(apperrors.BadRequest("food is not on an active menu"))
+			return
+		}
+
+		if err := h.priceOrderItem(ctx, &orderItem); err != nil {
+			c.Error(err)
+			return
+		}
+		if orderItem.SeatNumber != nil {
+			seats = append(seats, *orderItem.SeatNumber)
+		}
+		orderItems = append(orderItems, orderItem)
+	}
+
+	if err := h.checkSeats(ctx, &table.TableId, seats); err != nil {
+		c.Error(err)
+		return
+	}
+
+	order, err := h.openTableOrder(ctx, table.TableId)
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the table's order: %w", err)))
+		return
+	}
+	var newOrder *models.Order
+	orderId, heldFrom := "", 0
+	if order != nil {
+		orderId = order.OrderId
+		if heldFrom, err = h.heldCourseRank(ctx, orderId); err != nil {
+			c.Error(apperrors.Internal(fmt.Errorf("cannot get the held courses: %w", err)))
+			return
+		}
+	} else {
+		newOrder = &models.Order{TableId: &table.TableId, OrderType: models.OrderTypeDineIn}
+		newOrder.OrderDate, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		stampNewOrder(newOrder)
+		orderId = newOrder.OrderId
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	helpers.AssignFiring(orderItems, heldFrom, now)
+
+	for i := range orderItems {
+		orderItems[i].OrderId = orderId
+		orderItems[i].ApprovalStatus = models.ApprovalPending
+		orderItems[i].ID = primitive.NewObjectID()
+		orderItems[i].OrderItemId = orderItems[i].ID.Hex()
+		orderItems[i].CreatedAt = now
+		orderItems[i].UpdatedAt = now
+	}
+	if err := h.insertOrderWithItems(ctx, newOrder, orderItems); err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	c.JSON(201, gin.H{"status": "success", "data": gin.H{
+		"order_id":    orderId,
+		"order_items": orderItems,
+	}})
+}
+
+// guestTable resolves the table of the request's signed token, provided the
+// table's link was not rotated since. It fails the request itself and tells
+// whether the handler may go on.
+func (h *Handler) guestTable(ctx context.Context, c *gin.Context) (models.Table, bool) {
+	table := models.Table{}
+
+	tableId, nonce, err := helpers.VerifyTableLink(c.Param("token"))
+	if err != nil {
+		if errors.Is(err, helpers.ErrInvalidTableLink) {
+			c.Error(apperrors.Unauthorized(err.Error()))
+		} else {
+			c.Error(apperrors.Internal(fmt.Errorf("guest ordering is not configured: %w", err)))
+		}
+		return table, false
+	}
+
+	if err := h.Tables.FindByID(ctx, tableId, &table); err != nil || table.DeletedAt != nil {
+		c.Error(apperrors.NotFound("table not found"))
+		return table, false
+	}
+	if nonce != table.LinkNonce {
+		c.Error(apperrors.Unauthorized("this table link was replaced, scan the new code"))
+		return table, false
+	}
+
+	return table, true
+}
+
+// heldCourseRank is the rank of the earliest course the order still holds,
+// zero when it holds none. Rejected items are never fired and do not count.
+func (h *Handler) heldCourseRank(ctx context.Context, orderId string) (int, error) {
+	courses, err := h.OrderItems.Distinct(ctx, "course", bson.D{
+		{Key: "order_id", Value: orderId},
+		{Key: "fire_status", Value: models.FireStatusHeld},
+		{Key: "approval_status", Value: bson.D{{Key: "$ne", Value: models.ApprovalRejected}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	earliest := 0
+	for _, value := range courses {
+		course, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if rank := helpers.CourseRank(&course); rank > 0 && (earliest == 0 || rank < earliest) {
+			earliest = rank
+		}
+	}
+	return earliest, nil
+}
+
+// openTableOrder returns the table's latest dine-in order unless it has been
+// billed and every invoice for it is paid, nil when the table has no open
+// order.
+func (h *Handler) openTableOrder(ctx context.Context, tableId string) (*models.Order, error) {
+	order := models.Order{}
+	filter := bson.D{
+		{Key: "table_id", Value: tableId},
+		{Key: "order_type", Value: bson.D{{Key: "$in", Value: bson.A{models.OrderTypeDineIn, "", nil}}}},
+		notDeleted,
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "order_date", Value: -1}, {Key: "created_at", Value: -1}})
+	if err := h.Orders.FindOne(ctx, filter, opts).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	invoices, err := h.Invoices.CountDocuments(ctx, bson.D{{Key: "order_id", Value: order.OrderId}})
+	if err != nil {
+		return nil, err
+	}
+	unpaid, err := h.Invoices.CountDocuments(ctx, bson.D{
+		{Key: "order_id", Value: order.OrderId},
+		{Key: "payment_status", Value: bson.D{{Key: "$ne", Value: "PAID"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if invoices > 0 && unpaid == 0 {
+		return nil, nil
+	}
+
+	return &order, nil
+}
+
+// activeMenuFilter matches the menus that were not deleted and whose start
+// and end dates, when set, enclose now.
+func activeMenuFilter(now time.Time) bson.D {
+	return bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "start_date", Value: nil}},
+			bson.D{{Key: "start_date", Value: bson.D{{Key: "$lte", Value: now}}}},
+		}}},
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "end_date", Value: nil}},
+			bson.D{{Key: "end_date", Value: bson.D{{Key: "$gte", Value: now}}}},
+		}}},
+	}}, notDeleted}
+}
+
+func (h *Handler) activeMenuIds(ctx context.Context) ([]string, error) {
+	values, err := h.Menus.Distinct(ctx, "menu_id", activeMenuFilter(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	menuIds := []string{}
+	for _, value := range values {
+		if menuId, ok := value.(string); ok {
+			menuIds = append(menuIds, menuId)
+		}
+	}
+	return menuIds, nil
+}
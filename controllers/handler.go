@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/RahulMj21/mongo-restaurant-management/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the API from the repositories it is given, so handlers can
+// run against Mongo or against in-memory repositories.
+type Handler struct {
+	repositories.Repos
+	Blobs storage.BlobStore
+}
+
+func NewHandler(repos repositories.Repos, blobs storage.BlobStore) *Handler {
+	return &Handler{Repos: repos, Blobs: blobs}
+}
+
+// signedInUser is the id of the signed in user making the request, nil while
+// nobody is known. Records name who changed them with it, left empty rather
+// than refusing the write until authentication tells who that is.
+func signedInUser(c *gin.Context) *string {
+	uid := c.GetString("uid")
+	if uid == "" {
+		return nil
+	}
+	return &uid
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/middlewares"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestApp serves the routes register adds from a handler over in-memory
+// repositories, behind the same problem middleware as the server.
+func newTestApp(register func(app *gin.Engine, h *Handler)) (*gin.Engine, *Handler) {
+	h := NewHandler(repositories.NewMemoryRepos(), nil)
+	app := gin.New()
+	app.Use(middlewares.Problems)
+	register(app, h)
+	return app, h
+}
+
+// serve sends one request, headers going in name and value pairs.
+func serve(app *gin.Engine, method string, target string, body string, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	app.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// expectStatus fails the test unless the response has status, and decodes
+// the data of a successful response into data when it is not nil.
+func expectStatus(t *testing.T, response *httptest.ResponseRecorder, status int, data interface{}) {
+	t.Helper()
+	if response.Code != status {
+		t.Fatalf("status %d, want %d: %s", response.Code, status, response.Body.String())
+	}
+	if data == nil {
+		return
+	}
+	envelope := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("cannot read the response: %v", err)
+	}
+	if err := json.Unmarshal(envelope.Data, data); err != nil {
+		t.Fatalf("cannot read the data of the response: %v", err)
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestNoRouteAnswersProblem(t *testing.T) {
+	app, _ := newTestApp(func(app *gin.Engine, h *Handler) {
+		app.NoRoute(middlewares.NoRoute)
+	})
+
+	response := serve(app, http.MethodGet, "/missing", "")
+	expectStatus(t, response, http.StatusNotFound, nil)
+	if contentType := response.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/problem+json") {
+		t.Errorf("Content-Type %q, want a problem document", contentType)
+	}
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/RahulMj21/mongo-restaurant-management/storage"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (h *Handler) GetImage(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	image := models.Image{}
+	if err := h.Images.FindByID(ctx, c.Param("id"), &image); err != nil {
+		c.Error(apperrors.NotFound("image not found"))
+		return
+	}
+
+	setETag(c, image.Version)
+	c.JSON(200, gin.H{"status": "success", "data": image})
+}
+
+// UploadImage stores the multipart `file` and its thumbnail. Uploading an
+// image that is already stored returns the stored image.
+func (h *Handler) UploadImage(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	maxBytes := helpers.MaxImageBytes()
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.Error(apperrors.BadRequest("upload the image as the multipart field file"))
+		return
+	}
+	if fileHeader.Size > maxBytes {
+		c.Error(apperrors.TooLarge(helpers.ErrImageTooLarge.Error()))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		c.Error(apperrors.TooLarge(helpers.ErrImageTooLarge.Error()))
+		return
+	}
+
+	hash := storage.ContentKey(data)
+	existing := models.Image{}
+	err = h.Images.FindOne(ctx, bson.D{{Key: "hash", Value: hash}}).Decode(&existing)
+	if err == nil {
+		c.JSON(200, gin.H{"status": "success", "data": existing})
+		return
+	}
+	if err != mongo.ErrNoDocuments {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	processed, err := helpers.ProcessImage(data)
+	if errors.Is(err, helpers.ErrUnsupportedImage) {
+		c.Error(apperrors.UnsupportedMediaType(err.Error()))
+		return
+	}
+	if errors.Is(err, helpers.ErrImageTooLarge) {
+		c.Error(apperrors.TooLarge(err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	image := models.Image{
+		ContentType:          processed.ContentType,
+		Size:                 int64(len(data)),
+		Width:                processed.Width,
+		Height:               processed.Height,
+		Hash:                 hash,
+		ThumbnailHash:        storage.ContentKey(processed.Thumbnail),
+		ThumbnailContentType: processed.ThumbnailContentType,
+		ThumbnailWidth:       processed.ThumbnailWidth,
+		ThumbnailHeight:      processed.ThumbnailHeight,
+		UploadedBy:           signedInUser(c),
+	}
+	if err := h.Blobs.Put(ctx, image.Hash, data); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot store the image: %w", err)))
+		return
+	}
+	if err := h.Blobs.Put(ctx, image.ThumbnailHash, processed.Thumbnail); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot store the thumbnail: %w", err)))
+		return
+	}
+
+	image.ID = primitive.NewObjectID()
+	image.ImageId = image.ID.Hex()
+	image.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if _, err := h.Images.InsertOne(ctx, image); err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	c.JSON(201, gin.H{"status": "success", "data": image})
+}
+
+// DeleteImage removes an image no food uses any more, with its blobs.
+func (h *Handler) DeleteImage(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	image := models.Image{}
+	if err := h.Images.FindByID(ctx, c.Param("id"), &image); err != nil {
+		c.Error(apperrors.NotFound("image not found"))
+		return
+	}
+
+	used, err := h.Foods.CountDocuments(ctx, bson.D{{Key: "image_id", Value: image.ImageId}})
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	if used > 0 {
+		c.Error(apperrors.Conflict("image is still used by a food"))
+		return
+	}
+
+	result, err := h.Images.DeleteOne(ctx, bson.D{{Key: "image_id", Value: image.ImageId}})
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	// a concurrent upload of the same bytes may have recorded the hash again
+	for _, hash := range []string{image.Hash, image.ThumbnailHash} {
+		shared, err := h.Images.CountDocuments(ctx, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "hash", Value: hash}},
+			bson.D{{Key: "thumbnail_hash", Value: hash}},
+		}}})
+		if err != nil || shared > 0 {
+			continue
+		}
+		if err := h.Blobs.Delete(ctx, hash); err != nil {
+			log.Printf("cannot delete blob %s: %v", hash, err)
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": result})
+}
+
+func (h *Handler) GetImageContent(c *gin.Context) {
+	h.serveImage(c, false)
+}
+
+func (h *Handler) GetImageThumbnail(c *gin.Context) {
+	h.serveImage(c, true)
+}
+
+// serveImage sends the image's bytes. They never change for a hash, so
+// clients may cache them for good and revalidate with the hash as ETag.
+func (h *Handler) serveImage(c *gin.Context, thumbnail bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	image := models.Image{}
+	if err := h.Images.FindByID(ctx, c.Param("id"), &image); err != nil {
+		c.Error(apperrors.NotFound("image not found"))
+		return
+	}
+
+	hash, contentType := image.Hash, image.ContentType
+	if thumbnail {
+		hash, contentType = image.ThumbnailHash, image.ThumbnailContentType
+	}
+
+	etag := `"` + hash + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(304)
+		return
+	}
+
+	data, err := h.Blobs.Get(ctx, hash)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.Error(apperrors.NotFound("image content not found"))
+		return
+	}
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot read the image: %w", err)))
+		return
+	}
+
+	c.Data(200, contentType, data)
+}
+
+// checkImage makes sure the image a food refers to was uploaded.
+func (h *Handler) checkImage(ctx context.Context, imageId *string) error {
+	if imageId == nil {
+		return nil
+	}
+	count, err := h.Images.CountDocuments(ctx, bson.D{{Key: "image_id", Value: imageId}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperrors.BadRequest("image not found")
+	}
+	return nil
+}
@@ -2,86 +2,92 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
 	"time"
 
-	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
 	"github.com/RahulMj21/mongo-restaurant-management/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type InvoiceViewFormat struct {
 	Invoice_id       string
+	Invoice_number   string
 	Payment_method   string
 	Order_id         string
+	Order_type       string
+	Seat_numbers     []int
 	Payment_status   *string
 	Payment_due      interface{}
+	Delivery_fee     float64
 	Table_number     interface{}
 	Payment_due_date time.Time
 	Order_details    interface{}
 }
 
-var invoiceCollection = database.OpenCollection(database.Client, "invoice")
+// invoiceFilter matches an invoice by its human-readable number or by the
+// ObjectID hex it was created with, so older invoices stay reachable.
+func invoiceFilter(id string) bson.D {
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "invoice_number", Value: id}},
+		bson.D{{Key: "invoice_id", Value: id}},
+	}}}
+}
 
-func GetInvoices(c *gin.Context) {
+func (h *Handler) GetInvoices(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	result, err := invoiceCollection.Find(ctx, bson.M{})
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	query, ok := listQuery(c, invoiceListFields, "-created_at")
+	if !ok {
 		return
 	}
-
-	var invoices []primitive.M
-
-	if err := result.All(ctx, &invoices); err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	query.Filter = withoutDeleted(c, query.Filter)
+	if query.PageStages, _, ok = expandQuery(c, "invoice"); !ok {
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"status": "success",
-		"data":   invoices,
-	})
+	list(ctx, c, h.Invoices, query)
 }
 
-func GetInvoice(c *gin.Context) {
+func (h *Handler) GetInvoice(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
+	stages, expanded, ok := expandQuery(c, "invoice")
+	if !ok {
+		return
+	}
+
 	invoiceId := c.Param("id")
 
 	invoice := models.Invoice{}
-	err := invoiceCollection.FindOne(ctx, bson.D{{Key: "invoice_id", Value: invoiceId}}).Decode(&invoice)
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	err := h.Invoices.FindOne(ctx, invoiceFilter(invoiceId)).Decode(&invoice)
+	if err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("invoice not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
 	var invoiceView InvoiceViewFormat
 
-	allOrderItems, err := ItemsByOrderId(invoiceId)
+	bill, err := h.invoiceBill(ctx, invoice)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
 	invoiceView.Order_id = invoice.OrderId
+	invoiceView.Seat_numbers = invoice.SeatNumbers
 	invoiceView.Payment_due_date = invoice.PaymentDueDate
 
 	invoiceView.Payment_method = "null"
@@ -90,42 +96,50 @@ func GetInvoice(c *gin.Context) {
 	}
 
 	invoiceView.Invoice_id = invoice.InvoiceId
+	invoiceView.Invoice_number = invoice.InvoiceNumber
 	invoiceView.Payment_status = invoice.PaymentStatus
-	invoiceView.Payment_due = allOrderItems[0]["payment_due"]
-	invoiceView.Table_number = allOrderItems[0]["table_number"]
-	invoiceView.Order_details = allOrderItems[0]["order_items"]
+	invoiceView.Order_type = bill.orderType
+	invoiceView.Payment_due = bill.total
+	invoiceView.Delivery_fee = bill.deliveryFee
+	invoiceView.Table_number = bill.items["table_number"]
+	invoiceView.Order_details = bill.items["order_items"]
 
+	data, err := expandOne(ctx, c, h.Invoices, invoice.ID, stages, expanded, invoiceView)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	setETag(c, invoice.Version)
 	c.JSON(200, gin.H{
 		"status": "success",
-		"data":   invoiceView,
+		"data":   data,
 	})
 }
 
-func CreateInvoice(c *gin.Context) {
+func (h *Handler) CreateInvoice(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	order := models.Order{}
 	invoice := models.Invoice{}
 
-	if err := c.BindJSON(&invoice); err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": err.Error()})
+	if err := c.ShouldBindJSON(&invoice); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	if err := ordersCollection.FindOne(ctx, bson.D{{Key: "order_id", Value: invoice.OrderId}}).Decode(&order); err != nil {
-		c.JSON(404, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	if err := h.Orders.FindByID(ctx, invoice.OrderId, &order); err != nil || order.DeletedAt != nil {
+		c.Error(apperrors.NotFound("order not found"))
 		return
 	}
 
 	if err := validate.Struct(invoice); err != nil {
-		c.JSON(400, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if invoice.SeatNumbers != nil && len(invoice.SeatNumbers) == 0 {
+		c.Error(apperrors.BadRequest("seat_numbers needs a seat, leave it out to bill the whole order"))
 		return
 	}
 
@@ -134,79 +148,536 @@ func CreateInvoice(c *gin.Context) {
 	invoice.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	invoice.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	invoice.PaymentDueDate, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
+	invoice.RestaurantId = helpers.RestaurantId()
+	invoice.FiscalYear = helpers.FiscalYear(invoice.CreatedAt)
 
 	status := "PENDING"
 	if invoice.PaymentStatus == nil {
 		invoice.PaymentStatus = &status
 	}
 
-	insertedItem, err := invoiceCollection.InsertOne(ctx, invoice)
-	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+	if err := h.insertInvoices(ctx, invoice.OrderId, invoice.SeatNumbers, []models.Invoice{invoice}); err != nil {
+		c.Error(err)
 		return
 	}
 
 	newInvoice := models.Invoice{}
 
-	if err := invoiceCollection.FindOne(ctx, bson.M{"_id": insertedItem.InsertedID}).Decode(&newInvoice); err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+	if err := h.Invoices.FindOne(ctx, bson.M{"_id": invoice.ID}).Decode(&newInvoice); err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
 	c.JSON(201, gin.H{"status": "success", "data": newInvoice})
 }
 
-func UpdateInvoice(c *gin.Context) {
+// UpdateInvoice replaces the invoice on PUT and merge patches it on PATCH.
+// Only the payment method and status are the client's to set, numbering,
+// seats and payments stay as the server recorded them.
+func (h *Handler) UpdateInvoice(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	invoice := models.Invoice{}
+	current := models.Invoice{}
 	invoiceId := c.Param("id")
 
-	if err := c.BindJSON(&invoice); err != nil {
-		c.JSON(400, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
+	if err := h.Invoices.FindOne(ctx, invoiceFilter(invoiceId)).Decode(&current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("invoice not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
+
+	next := models.Invoice{}
+	if err := readUpdate(c, current, &next); err != nil {
+		updateBodyFailed(c, err)
+		return
+	}
+	invoice := current
+	invoice.PaymentMethod = next.PaymentMethod
+	invoice.PaymentStatus = next.PaymentStatus
+
+	if err := validate.Struct(invoice); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	invoice.Version = current.Version + 1
+	invoice.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if err := replaceDocument(ctx, h.Invoices, invoice.ID, current.Version, invoice); err != nil {
+		updateFailed(c, err, "invoice not found")
+		return
+	}
+
+	if *invoice.PaymentStatus == "PAID" {
+		total, err := h.invoiceTotal(ctx, invoice)
+		if err == nil {
+			err = h.awardLoyalty(ctx, invoice, total)
+		}
+		if err == nil {
+			// awarding marks the invoice
+			err = h.Invoices.FindOne(ctx, bson.D{{Key: "_id", Value: invoice.ID}}).Decode(&invoice)
+		}
+		if err != nil {
+			c.Error(apperrors.Internal(err))
+			return
+		}
+	}
+
+	setETag(c, invoice.Version)
+	c.JSON(200, gin.H{"status": "success", "data": invoice})
+}
+
+// insertInvoices bills invoices on the order in one transaction. Marking the
+// order billed first makes two billings of the same order conflict, and the
+// one retried after the other committed sees its invoices and refuses to bill
+// the same seats again. Every invoice takes the next number from the
+// restaurant's fiscal year sequence, and since all writes share the
+// transaction a failed insert never burns a number. Deployments without
+// transaction support can promise neither and bill nothing.
+func (h *Handler) insertInvoices(ctx context.Context, orderId string, seats []int, invoices []models.Invoice) error {
+	if !h.Transactions.SupportsTransactions(ctx) {
+		return apperrors.Unavailable("invoices can only be numbered on a replica set or sharded cluster")
+	}
+
+	return h.Transactions.WithTransaction(ctx, func(sessCtx context.Context) error {
+		billedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := h.Orders.UpdateOne(sessCtx, bson.D{{Key: "order_id", Value: orderId}, notDeleted}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "billed_at", Value: billedAt}}},
+			bumpVersion,
 		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return apperrors.NotFound("order not found")
+		}
+
+		if err := h.checkInvoiceOverlap(sessCtx, orderId, seats); err != nil {
+			return err
+		}
+
+		for i := range invoices {
+			counterKey := helpers.InvoiceCounterKey(invoices[i].RestaurantId, invoices[i].FiscalYear)
+			seq, err := helpers.NextSequence(sessCtx, h.Counters, counterKey)
+			if err != nil {
+				return err
+			}
+			invoices[i].InvoiceNumber = helpers.FormatInvoiceNumber(invoices[i].FiscalYear, seq)
+
+			if _, err := h.Invoices.InsertOne(sessCtx, invoices[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type SplitBySeatBody struct {
+	OrderId       string  `json:"order_id" validate:"required"`
+	PaymentMethod *string `json:"payment_method" validate:"omitempty,eq=CASH|eq=CARD"`
+}
+
+// SplitInvoiceBySeat bills every seat of an order on its own invoice. Items
+// that were not ordered for a seat go on one more invoice for seat 0.
+func (h *Handler) SplitInvoiceBySeat(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	body := SplitBySeatBody{}
+	order := models.Order{}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if err := validate.Struct(body); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	filter := bson.D{{Key: "invoice_id", Value: invoiceId}}
+	if err := h.Orders.FindByID(ctx, body.OrderId, &order); err != nil || order.DeletedAt != nil {
+		c.Error(apperrors.NotFound("order not found"))
+		return
+	}
 
-	var invoiceObj primitive.D
+	values, err := h.OrderItems.Distinct(ctx, "seat_number", bson.D{{Key: "order_id", Value: body.OrderId}, approvedItems})
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	seats := []int{}
+	for _, value := range values {
+		switch seat := value.(type) {
+		case int32:
+			seats = append(seats, int(seat))
+		case int64:
+			seats = append(seats, int(seat))
+		}
+	}
+	sort.Ints(seats)
 
-	if invoice.PaymentMethod != nil {
-		invoiceObj = append(invoiceObj, bson.E{Key: "payment_method", Value: invoice.PaymentMethod})
+	unseated, err := h.OrderItems.CountDocuments(ctx, bson.D{
+		{Key: "order_id", Value: body.OrderId},
+		{Key: "seat_number", Value: nil},
+		approvedItems,
+	})
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
 	}
-	if invoice.PaymentStatus != nil {
-		invoiceObj = append(invoiceObj, bson.E{Key: "payment_status", Value: invoice.PaymentStatus})
+	if unseated > 0 {
+		seats = append(seats, 0)
+	}
+	if len(seats) == 0 {
+		c.Error(apperrors.BadRequest("order has no items to bill"))
+		return
 	}
 
-	invoice.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	invoiceObj = append(invoiceObj, bson.E{Key: "updated_at", Value: invoice.UpdatedAt})
+	invoices := []models.Invoice{}
+	for _, seat := range seats {
+		status := "PENDING"
+		invoice := models.Invoice{
+			OrderId:       body.OrderId,
+			SeatNumbers:   []int{seat},
+			PaymentMethod: body.PaymentMethod,
+			PaymentStatus: &status,
+		}
+		invoice.ID = primitive.NewObjectID()
+		invoice.InvoiceId = invoice.ID.Hex()
+		invoice.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		invoice.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		invoice.PaymentDueDate, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
+		invoice.RestaurantId = helpers.RestaurantId()
+		invoice.FiscalYear = helpers.FiscalYear(invoice.CreatedAt)
+		invoices = append(invoices, invoice)
+	}
+
+	if err := h.insertInvoices(ctx, body.OrderId, seats, invoices); err != nil {
+		c.Error(err)
+		return
+	}
 
-	upsert := true
+	c.JSON(201, gin.H{"status": "success", "data": invoices})
+}
 
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+// checkInvoiceOverlap refuses an invoice that would bill items already billed
+// on another invoice of the order. An invoice without seat numbers bills the
+// whole order, so it can be the only one. Deleted invoices bill nothing.
+func (h *Handler) checkInvoiceOverlap(ctx context.Context, orderId string, seats []int) error {
+	cursor, err := h.Invoices.Find(ctx, bson.D{{Key: "order_id", Value: orderId}, notDeleted})
+	if err != nil {
+		return err
+	}
+	existing := []models.Invoice{}
+	if err := cursor.All(ctx, &existing); err != nil {
+		return err
 	}
 
-	status := "PENDING"
-	if invoice.PaymentStatus == nil {
-		invoice.PaymentStatus = &status
+	billed := map[int]string{}
+	for _, invoice := range existing {
+		if len(invoice.SeatNumbers) == 0 {
+			return apperrors.Conflict("order is already billed in full on invoice " + invoice.InvoiceNumber)
+		}
+		for _, seat := range invoice.SeatNumbers {
+			billed[seat] = invoice.InvoiceNumber
+		}
 	}
 
-	result, err := invoiceCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: invoiceObj}}, &opt)
+	if len(seats) == 0 && len(billed) > 0 {
+		return apperrors.Conflict("order is already billed by seat")
+	}
+	for _, seat := range seats {
+		if invoiceNumber, ok := billed[seat]; ok {
+			return apperrors.Conflict(fmt.Sprintf("seat %d is already billed on invoice %s", seat, invoiceNumber))
+		}
+	}
+
+	return nil
+}
+
+type PaymentBody struct {
+	Method       string  `json:"method" validate:"required,eq=CASH|eq=CARD|eq=LOYALTY|eq=GIFT_CARD"`
+	Amount       float64 `json:"amount" validate:"min=0"`
+	Points       int     `json:"points" validate:"min=0"`
+	GiftCardCode string  `json:"gift_card_code" validate:"required_if=Method GIFT_CARD"`
+}
+
+// AddInvoicePayment settles an invoice in full or in part. Loyalty points are
+// taken from the customer of the order and pay LOYALTY_POINT_VALUE each; when
+// no points are given, enough are redeemed to pay what is left. A gift card
+// pays the given amount, or as much of the bill as its balance covers. Once the
+// payments cover the bill the invoice is marked PAID and the customer earns
+// points for the part paid in money.
+func (h *Handler) AddInvoicePayment(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	invoiceId := c.Param("id")
+	body := PaymentBody{}
+	invoice := models.Invoice{}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if err := validate.Struct(body); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := h.Invoices.FindOne(ctx, invoiceFilter(invoiceId)).Decode(&invoice); err != nil {
+		c.Error(apperrors.NotFound("invoice not found"))
+		return
+	}
+	if invoice.DeletedAt != nil {
+		c.Error(apperrors.Conflict("invoice was deleted"))
+		return
+	}
+	if invoice.PaymentStatus != nil && *invoice.PaymentStatus == "PAID" {
+		c.Error(apperrors.Conflict("invoice is already paid"))
+		return
+	}
+
+	total, err := h.invoiceTotal(ctx, invoice)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	remaining := helpers.RoundPrice(total - invoice.AmountPaid)
+	if remaining <= 0 {
+		c.Error(apperrors.Conflict("nothing is due on this invoice"))
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"status": "success",
-		"data":   result,
+	payment := models.Payment{
+		PaymentId: primitive.NewObjectID().Hex(),
+		Method:    body.Method,
+	}
+	payment.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	// redeem takes the payment off the points or gift card it is made with,
+	// refund gives it back when the payment cannot be stored
+	redeem := func(ctx context.Context) error { return nil }
+	refund := func(ctx context.Context) error { return nil }
+
+	switch body.Method {
+	case "LOYALTY":
+		order := models.Order{}
+		if err := h.Orders.FindByID(ctx, invoice.OrderId, &order); err != nil {
+			c.Error(apperrors.NotFound("order not found"))
+			return
+		}
+		if order.CustomerId == nil {
+			c.Error(apperrors.BadRequest("order has no customer to redeem points from"))
+			return
+		}
+
+		points := body.Points
+		if points == 0 || helpers.PointsValue(points) > remaining {
+			points = helpers.PointsNeeded(remaining)
+		}
+		payment.Points = points
+		payment.Amount = helpers.PointsValue(points)
+		if payment.Amount > remaining {
+			payment.Amount = remaining
+		}
+
+		customerId := *order.CustomerId
+		redeem = func(ctx context.Context) error {
+			return h.redeemLoyaltyPoints(ctx, customerId, invoice.InvoiceId, points)
+		}
+		refund = func(ctx context.Context) error {
+			return h.refundLoyaltyPoints(ctx, customerId, invoice.InvoiceId, points)
+		}
+	case "GIFT_CARD":
+		giftCard := models.GiftCard{}
+		if err := h.findGiftCard(ctx, body.GiftCardCode, &giftCard); err != nil {
+			c.Error(apperrors.NotFound("gift card not found"))
+			return
+		}
+
+		payment.Amount = helpers.RoundPrice(body.Amount)
+		if payment.Amount == 0 {
+			payment.Amount = math.Min(remaining, giftCard.Balance)
+		}
+		if payment.Amount <= 0 {
+			c.Error(apperrors.Conflict("gift card has no balance left"))
+			return
+		}
+		if payment.Amount > remaining {
+			c.Error(apperrors.BadRequest(fmt.Sprintf("only %.2f is still due", remaining)))
+			return
+		}
+
+		payment.GiftCardCode = giftCard.Code
+		amount := payment.Amount
+		redeem = func(ctx context.Context) error {
+			_, err := h.redeemGiftCard(ctx, giftCard.Code, invoice.InvoiceId, amount)
+			return err
+		}
+		refund = func(ctx context.Context) error {
+			return h.refundGiftCard(ctx, giftCard.GiftCardId, invoice.InvoiceId, amount)
+		}
+	default:
+		payment.Amount = helpers.RoundPrice(body.Amount)
+		if payment.Amount <= 0 {
+			c.Error(apperrors.BadRequest("amount must be greater than zero"))
+			return
+		}
+		if payment.Amount > remaining {
+			c.Error(apperrors.BadRequest(fmt.Sprintf("only %.2f is still due", remaining)))
+			return
+		}
+	}
+
+	payments := append(invoice.Payments, payment)
+	amountPaid := helpers.RoundPrice(invoice.AmountPaid + payment.Amount)
+	paid := amountPaid >= helpers.RoundPrice(total)
+
+	invoiceObj := bson.D{{Key: "amount_paid", Value: amountPaid}}
+	if paid {
+		paidStatus := "PAID"
+		invoiceObj = append(invoiceObj, bson.E{Key: "payment_status", Value: paidStatus})
+		invoiceObj = append(invoiceObj, bson.E{Key: "payment_method", Value: settledMethod(payments)})
+	}
+	invoice.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	invoiceObj = append(invoiceObj, bson.E{Key: "updated_at", Value: invoice.UpdatedAt})
+
+	// The amount already paid is part of the filter, so of two payments
+	// recorded at the same time only one goes through and the bill is never
+	// paid twice.
+	previouslyPaid := bson.E{Key: "amount_paid", Value: invoice.AmountPaid}
+	if invoice.AmountPaid == 0 {
+		previouslyPaid.Value = bson.D{{Key: "$in", Value: bson.A{0, nil}}}
+	}
+	filter := bson.D{
+		{Key: "_id", Value: invoice.ID},
+		{Key: "payment_status", Value: bson.D{{Key: "$ne", Value: "PAID"}}},
+		previouslyPaid,
+	}
+	update := bson.D{
+		{Key: "$set", Value: invoiceObj},
+		{Key: "$push", Value: bson.D{{Key: "payments", Value: payment}}},
+		bumpVersion,
+	}
+
+	// The redemption and the payment are stored together. Without
+	// transactions the redemption is stored already when the invoice update
+	// fails, and is given back.
+	err = h.Transactions.WithTransaction(ctx, func(sessCtx context.Context) error {
+		if err := redeem(sessCtx); err != nil {
+			return err
+		}
+
+		result, err := h.Invoices.UpdateOne(sessCtx, filter, update)
+		if err == nil && result.MatchedCount == 0 {
+			err = apperrors.Conflict("invoice changed while paying, please try again")
+		}
+		if err != nil && !h.Transactions.SupportsTransactions(sessCtx) {
+			if refundErr := refund(ctx); refundErr != nil {
+				log.Printf("cannot give back the %s payment %s of invoice %s: %v", payment.Method, payment.PaymentId, invoice.InvoiceId, refundErr)
+			}
+		}
+		return err
 	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if paid {
+		invoice.Payments = payments
+		if err := h.awardLoyalty(ctx, invoice, total); err != nil {
+			c.Error(apperrors.Internal(err))
+			return
+		}
+	}
+
+	newInvoice := models.Invoice{}
+	if err := h.Invoices.FindOne(ctx, bson.D{{Key: "_id", Value: invoice.ID}}).Decode(&newInvoice); err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": newInvoice})
+}
+
+type bill struct {
+	items       primitive.M
+	orderType   string
+	deliveryFee float64
+	total       float64
+}
+
+// invoiceBill works out what the invoice bills: the items of the whole order,
+// or only of the seats it was split for, plus the delivery fee on the invoice
+// that covers the order's shared items.
+func (h *Handler) invoiceBill(ctx context.Context, invoice models.Invoice) (bill, error) {
+	result := bill{items: primitive.M{"payment_due": 0, "order_items": []primitive.M{}}}
+
+	order := models.Order{}
+	if err := h.Orders.FindByID(ctx, invoice.OrderId, &order); err != nil {
+		return result, err
+	}
+	result.orderType = order.OrderType
+	if result.orderType == "" {
+		result.orderType = models.OrderTypeDineIn
+	}
+
+	allOrderItems, err := h.ItemsByOrderIdAndSeats(invoice.OrderId, invoice.SeatNumbers)
+	if err != nil {
+		return result, err
+	}
+	if len(allOrderItems) > 0 {
+		result.items = allOrderItems[0]
+	}
+
+	switch due := result.items["payment_due"].(type) {
+	case float64:
+		result.total = due
+	case int32:
+		result.total = float64(due)
+	case int64:
+		result.total = float64(due)
+	}
+
+	coversSharedItems := len(invoice.SeatNumbers) == 0
+	for _, seat := range invoice.SeatNumbers {
+		if seat == 0 {
+			coversSharedItems = true
+		}
+	}
+	if order.DeliveryFee != nil && coversSharedItems {
+		result.deliveryFee = *order.DeliveryFee
+		result.total += result.deliveryFee
+	}
+	result.total = helpers.RoundPrice(result.total)
+
+	return result, nil
+}
+
+// invoiceTotal is the amount the invoice bills.
+func (h *Handler) invoiceTotal(ctx context.Context, invoice models.Invoice) (float64, error) {
+	bill, err := h.invoiceBill(ctx, invoice)
+	return bill.total, err
+}
+
+// settledMethod names the way an invoice was paid, MIXED when the guest used
+// more than one.
+func settledMethod(payments []models.Payment) string {
+	method := ""
+	for _, payment := range payments {
+		if method != "" && payment.Method != method {
+			return "MIXED"
+		}
+		method = payment.Method
+	}
+	return method
 }
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetKitchenQueue lists the fired order items the kitchen has to prepare in
+// the order they were fired, with everything the cooks need on the ticket.
+// Items fired before the `since` query parameter (RFC3339, default twelve
+// hours ago) are left out. Held courses stay off the queue until fired and
+// guest items until a waiter approves them, and the items of deleted orders
+// never show. Every ticket lists its allergens and raises allergy_alerts for
+// those the order's customer is allergic to.
+func (h *Handler) GetKitchenQueue(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	since := time.Now().Add(-12 * time.Hour)
+	if c.Query("since") != "" {
+		parsed, err := time.Parse(time.RFC3339, c.Query("since"))
+		if err != nil {
+			c.Error(apperrors.BadRequest("since must be an RFC3339 timestamp"))
+			return
+		}
+		since = parsed
+	}
+
+	// a bundle reaches the kitchen as its components, the bundle item itself is only billed
+	// items stored before courses existed have no fire status and went
+	// to the kitchen as soon as they were created
+	matchStage := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "item_type", Value: bson.D{{Key: "$ne", Value: models.OrderItemTypeBundle}}},
+		{Key: "fire_status", Value: bson.D{{Key: "$ne", Value: models.FireStatusHeld}}},
+		approvedItems,
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "fired_at", Value: bson.D{{Key: "$gte", Value: since}}}},
+			bson.D{
+				{Key: "fired_at", Value: nil},
+				{Key: "created_at", Value: bson.D{{Key: "$gte", Value: since}}},
+			},
+		}},
+	}}}
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{
+		{Key: "fired_at", Value: 1},
+		{Key: "created_at", Value: 1},
+	}}}
+	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "food"},
+		{Key: "localField", Value: "food_id"},
+		{Key: "foreignField", Value: "food_id"},
+		{Key: "as", Value: "food"},
+	}}}
+	unwindStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$food"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	lookupBundleStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "bundle"},
+		{Key: "localField", Value: "bundle_id"},
+		{Key: "foreignField", Value: "bundle_id"},
+		{Key: "as", Value: "bundle"},
+	}}}
+	unwindBundleStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$bundle"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	lookupOrderStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "order"},
+		{Key: "localField", Value: "order_id"},
+		{Key: "foreignField", Value: "order_id"},
+		{Key: "as", Value: "order"},
+	}}}
+	unwindOrderStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$order"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	// the items of a deleted order are not cooked
+	liveOrderStage := bson.D{{Key: "$match", Value: bson.D{{Key: "order.deleted_at", Value: nil}}}}
+	lookupTableStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "table"},
+		{Key: "localField", Value: "order.table_id"},
+		{Key: "foreignField", Value: "table_id"},
+		{Key: "as", Value: "table"},
+	}}}
+	unwindTableStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$table"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	lookupCustomerStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "customer"},
+		{Key: "localField", Value: "order.customer_id"},
+		{Key: "foreignField", Value: "customer_id"},
+		{Key: "as", Value: "customer"},
+	}}}
+	unwindCustomerStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$customer"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	// the ticket's allergens are the food's plus those the chosen modifiers
+	// add. Customers enter their allergies as free text, so they are matched
+	// against the allergen codes case and spacing insensitively.
+	allergensStage := bson.D{{Key: "$addFields", Value: bson.D{
+		{Key: "allergens", Value: bson.D{{Key: "$setUnion", Value: bson.A{
+			bson.D{{Key: "$ifNull", Value: bson.A{"$food.allergens", bson.A{}}}},
+			bson.D{{Key: "$reduce", Value: bson.D{
+				{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$modifiers", bson.A{}}}}},
+				{Key: "initialValue", Value: bson.A{}},
+				{Key: "in", Value: bson.D{{Key: "$concatArrays", Value: bson.A{
+					"$$value",
+					bson.D{{Key: "$ifNull", Value: bson.A{"$$this.allergens", bson.A{}}}},
+				}}}},
+			}}},
+		}}}},
+		{Key: "customer_allergies", Value: bson.D{{Key: "$map", Value: bson.D{
+			{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$customer.allergies", bson.A{}}}}},
+			{Key: "as", Value: "allergy"},
+			{Key: "in", Value: bson.D{{Key: "$replaceAll", Value: bson.D{
+				{Key: "input", Value: bson.D{{Key: "$toUpper", Value: bson.D{{Key: "$trim", Value: bson.D{{Key: "input", Value: "$$allergy"}}}}}}},
+				{Key: "find", Value: " "},
+				{Key: "replacement", Value: "_"},
+			}}}},
+		}}}},
+	}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "order_item_id", Value: 1},
+		{Key: "order_id", Value: 1},
+		{Key: "order_type", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$order.order_type", models.OrderTypeDineIn}}}},
+		{Key: "pickup_time", Value: "$order.pickup_time"},
+		{Key: "table_number", Value: "$table.table_number"},
+		{Key: "seat_number", Value: 1},
+		{Key: "food_name", Value: "$food.name"},
+		{Key: "bundle_name", Value: "$bundle.name"},
+		{Key: "parent_order_item_id", Value: 1},
+		{Key: "portion_size", Value: 1},
+		{Key: "quantity", Value: 1},
+		{Key: "modifiers", Value: bson.D{{Key: "$map", Value: bson.D{
+			{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$modifiers", bson.A{}}}}},
+			{Key: "as", Value: "modifier"},
+			{Key: "in", Value: bson.D{
+				{Key: "group_name", Value: "$$modifier.group_name"},
+				{Key: "name", Value: "$$modifier.name"},
+			}},
+		}}}},
+		{Key: "special_instructions", Value: 1},
+		{Key: "allergens", Value: 1},
+		{Key: "allergy_alerts", Value: bson.D{{Key: "$setIntersection", Value: bson.A{"$allergens", "$customer_allergies"}}}},
+		{Key: "course", Value: 1},
+		{Key: "created_at", Value: 1},
+		{Key: "fired_at", Value: 1},
+	}}}
+
+	cursor, err := h.OrderItems.Aggregate(ctx, mongo.Pipeline{
+		matchStage,
+		sortStage,
+		lookupStage,
+		unwindStage,
+		lookupBundleStage,
+		unwindBundleStage,
+		lookupOrderStage,
+		unwindOrderStage,
+		liveOrderStage,
+		lookupTableStage,
+		unwindTableStage,
+		lookupCustomerStage,
+		unwindCustomerStage,
+		allergensStage,
+		projectStage,
+	})
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the kitchen queue: %w", err)))
+		return
+	}
+
+	tickets := []primitive.M{}
+	if err := cursor.All(ctx, &tickets); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the kitchen queue: %w", err)))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": tickets})
+}
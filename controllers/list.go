@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The fields each list endpoint can be filtered and sorted on.
+var (
+	foodListFields = helpers.ListFields{
+		"food_id":    helpers.StringField,
+		"menu_id":    helpers.StringField,
+		"name":       helpers.TextField,
+		"price":      helpers.NumberField,
+		"created_at": helpers.TimeField,
+		"updated_at": helpers.TimeField,
+		"deleted_at": helpers.TimeField,
+	}
+	menuListFields = helpers.ListFields{
+		"menu_id":    helpers.StringField,
+		"name":       helpers.TextField,
+		"category":   helpers.StringField,
+		"start_date": helpers.TimeField,
+		"end_date":   helpers.TimeField,
+		"created_at": helpers.TimeField,
+		"updated_at": helpers.TimeField,
+		"deleted_at": helpers.TimeField,
+	}
+	tableListFields = helpers.ListFields{
+		"table_id":         helpers.StringField,
+		"table_number":     helpers.NumberField,
+		"number_of_guests": helpers.NumberField,
+		"created_at":       helpers.TimeField,
+		"updated_at":       helpers.TimeField,
+		"deleted_at":       helpers.TimeField,
+	}
+	orderListFields = helpers.ListFields{
+		"order_id":    helpers.StringField,
+		"order_type":  helpers.StringField,
+		"table_id":    helpers.StringField,
+		"customer_id": helpers.StringField,
+		"driver_id":   helpers.StringField,
+		"order_date":  helpers.TimeField,
+		"pickup_time": helpers.TimeField,
+		"created_at":  helpers.TimeField,
+		"updated_at":  helpers.TimeField,
+		"deleted_at":  helpers.TimeField,
+	}
+	orderItemListFields = helpers.ListFields{
+		"order_item_id":   helpers.StringField,
+		"order_id":        helpers.StringField,
+		"food_id":         helpers.StringField,
+		"bundle_id":       helpers.StringField,
+		"item_type":       helpers.StringField,
+		"course":          helpers.StringField,
+		"seat_number":     helpers.NumberField,
+		"fire_status":     helpers.StringField,
+		"approval_status": helpers.StringField,
+		"unit_price":      helpers.NumberField,
+		"created_at":      helpers.TimeField,
+		"updated_at":      helpers.TimeField,
+	}
+	invoiceListFields = helpers.ListFields{
+		"invoice_id":       helpers.StringField,
+		"invoice_number":   helpers.StringField,
+		"order_id":         helpers.StringField,
+		"fiscal_year":      helpers.NumberField,
+		"payment_method":   helpers.StringField,
+		"payment_status":   helpers.StringField,
+		"amount_paid":      helpers.NumberField,
+		"payment_due_date": helpers.TimeField,
+		"created_at":       helpers.TimeField,
+		"updated_at":       helpers.TimeField,
+		"deleted_at":       helpers.TimeField,
+	}
+	userListFields = helpers.ListFields{
+		"user_id":    helpers.StringField,
+		"first_name": helpers.TextField,
+		"last_name":  helpers.TextField,
+		"email":      helpers.StringField,
+		"phone":      helpers.StringField,
+		"created_at": helpers.TimeField,
+		"updated_at": helpers.TimeField,
+	}
+	customerListFields = helpers.ListFields{
+		"customer_id":    helpers.StringField,
+		"name":           helpers.TextField,
+		"phone":          helpers.StringField,
+		"email":          helpers.StringField,
+		"loyalty_points": helpers.NumberField,
+		"lifetime_spend": helpers.NumberField,
+		"last_visit_at":  helpers.TimeField,
+		"created_at":     helpers.TimeField,
+		"updated_at":     helpers.TimeField,
+	}
+	bundleListFields = helpers.ListFields{
+		"bundle_id":  helpers.StringField,
+		"menu_id":    helpers.StringField,
+		"name":       helpers.TextField,
+		"price":      helpers.NumberField,
+		"created_at": helpers.TimeField,
+		"updated_at": helpers.TimeField,
+	}
+	giftCardListFields = helpers.ListFields{
+		"gift_card_id": helpers.StringField,
+		"code":         helpers.StringField,
+		"status":       helpers.StringField,
+		"balance":      helpers.NumberField,
+		"expires_at":   helpers.TimeField,
+		"created_at":   helpers.TimeField,
+		"updated_at":   helpers.TimeField,
+	}
+	// search results are ranked by their score against the words searched
+	searchListFields = helpers.ListFields{
+		"score": helpers.NumberField,
+		"name":  helpers.TextField,
+	}
+	noteListFields = helpers.ListFields{
+		"entity_type": helpers.StringField,
+		"entity_id":   helpers.StringField,
+		"category":    helpers.StringField,
+		"pinned":      helpers.BoolField,
+		"created_at":  helpers.TimeField,
+		"updated_at":  helpers.TimeField,
+	}
+)
+
+// listQuery reads the filters, sort and page of a list request. It fails the
+// request itself and tells whether the handler may go on.
+func listQuery(c *gin.Context, fields helpers.ListFields, defaultSort string) (helpers.ListQuery, bool) {
+	query, err := helpers.ParseListQuery(c.Request.URL.Query(), fields, defaultSort)
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return query, false
+	}
+	return query, true
+}
+
+// listPage is a page of a list and what else its pipeline counted.
+type listPage struct {
+	documents []primitive.M
+	total     int64
+	next      string
+	// facets holds the branches of the query's Facets by name
+	facets primitive.M
+}
+
+// findPage runs the list pipeline of query on collection and cuts its page
+// down to the limit.
+func findPage(ctx context.Context, collection repositories.Collection, query helpers.ListQuery, stages ...bson.D) (listPage, error) {
+	page := listPage{documents: []primitive.M{}, facets: primitive.M{}}
+
+	cursor, err := collection.Aggregate(ctx, query.Pipeline(stages...))
+	if err != nil {
+		return page, err
+	}
+
+	result := []struct {
+		Data  []primitive.M `bson:"data"`
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Facets primitive.M `bson:",inline"`
+	}{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return page, err
+	}
+
+	if len(result) > 0 {
+		if result[0].Data != nil {
+			page.documents = result[0].Data
+		}
+		if len(result[0].Total) > 0 {
+			page.total = result[0].Total[0].Count
+		}
+		if result[0].Facets != nil {
+			page.facets = result[0].Facets
+		}
+	}
+	page.documents, page.next, err = query.Page(page.documents)
+	return page, err
+}
+
+func (page listPage) pagination(query helpers.ListQuery) gin.H {
+	return gin.H{
+		"total":       page.total,
+		"limit":       query.Limit,
+		"offset":      query.Offset,
+		"next_cursor": page.next,
+	}
+}
+
+// list answers a list request with a page of the collection, keeping the
+// ?fields= of each document. The stages run on the matching documents before
+// they are sorted and paged.
+func list(ctx context.Context, c *gin.Context, collection repositories.Collection, query helpers.ListQuery, stages ...bson.D) {
+	page, err := findPage(ctx, collection, query, stages...)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	data, err := helpers.SelectFields(page.documents, c.Query("fields"))
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": data, "pagination": page.pagination(query)})
+}
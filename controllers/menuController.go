@@ -2,71 +2,84 @@ package controllers
 
 import (
 	"context"
-	"log"
 	"time"
 
-	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
 	"github.com/RahulMj21/mongo-restaurant-management/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
-
-func GetMenus(c *gin.Context) {
+func (h *Handler) GetMenus(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	cursor, err := menuCollection.Find(ctx, bson.M{})
 	defer cancel()
-	if err != nil {
-		c.JSON(500, gin.H{"error": "error while fetching menus"})
+
+	query, ok := listQuery(c, menuListFields, "name")
+	if !ok {
+		return
 	}
+	query.Filter = withoutDeleted(c, query.Filter)
 
-	var allMenus []bson.M
-	if err = cursor.All(ctx, &allMenus); err != nil {
-		log.Fatal(err)
+	chain, ok := languageChain(c)
+	if !ok {
+		return
 	}
-	c.JSON(200, allMenus)
+
+	list(ctx, c, h.Menus, query, helpers.LocalizeStage(chain))
 }
 
-func GetMenu(c *gin.Context) {
+func (h *Handler) GetMenu(c *gin.Context) {
+	chain, ok := languageChain(c)
+	if !ok {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 
 	menu_id := c.Param("id")
 	menu := models.Menu{}
 
-	err := menuCollection.FindOne(ctx, bson.D{{Key: "menu_id", Value: menu_id}}).Decode(&menu)
+	err := h.Menus.FindByID(ctx, menu_id, &menu)
 	defer cancel()
-	if err != nil {
-		c.JSON(404, gin.H{"error": err.Error()})
+	if err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("menu not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
 	}
 
+	menu.Name, menu.Description, menu.Language = helpers.Localize(chain, menu.Name, menu.Description, menu.Translations)
+	c.Header("Content-Language", menu.Language)
+
+	setETag(c, menu.Version)
 	c.JSON(200, gin.H{"status": "success", "data": menu})
 }
 
-func CreateMenu(c *gin.Context) {
+func (h *Handler) CreateMenu(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	menu := models.Menu{}
 
-	err := c.BindJSON(&menu)
+	err := c.ShouldBindJSON(&menu)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	validationErr := validate.Struct(menu)
 	if validationErr != nil {
-		c.JSON(400, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Validation(validationErr))
+		return
+	}
+
+	menu.Translations, err = helpers.PrepareTranslations(menu.Translations)
+	if err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
@@ -75,85 +88,132 @@ func CreateMenu(c *gin.Context) {
 	menu.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	menu.MenuId = menu.ID.Hex()
 
-	insertedItem, err := menuCollection.InsertOne(ctx, menu)
+	insertedItem, err := h.Menus.InsertOne(ctx, menu)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status": "fail",
-			"data":   err.Error(),
-		})
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	var newItem primitive.D
-	err = menuCollection.FindOne(ctx, bson.D{{Key: "_id", Value: insertedItem.InsertedID}}).Decode(&newItem)
+	newItem := models.Menu{}
+	err = h.Menus.FindOne(ctx, bson.D{{Key: "_id", Value: insertedItem.InsertedID}}).Decode(&newItem)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	c.JSON(500, gin.H{
+	setETag(c, newItem.Version)
+	c.JSON(201, gin.H{
 		"status": "success",
 		"data":   newItem,
 	})
 }
 
-func UpdateMenu(c *gin.Context) {
+// UpdateMenu replaces the menu on PUT and merge patches it on PATCH. New
+// dates have to lie ahead, dates already stored are left alone.
+func (h *Handler) UpdateMenu(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	menu := models.Menu{}
+	current := models.Menu{}
+	menuId := c.Param("id")
 
-	if err := c.BindJSON(&menu); err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": err.Error()})
+	if err := h.Menus.FindByID(ctx, menuId, &current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("menu not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	menuId := c.Param("id")
-	filter := bson.D{{Key: "menu_id", Value: menuId}}
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
 
-	var menuObj primitive.D
+	menu := models.Menu{}
+	if err := readUpdate(c, current, &menu); err != nil {
+		updateBodyFailed(c, err)
+		return
+	}
+	menu.ID, menu.MenuId, menu.CreatedAt = current.ID, current.MenuId, current.CreatedAt
+	menu.DeletedAt, menu.DeletedBy = current.DeletedAt, current.DeletedBy
+	menu.Language = ""
 
-	if menu.StartDate != nil && menu.EndDate != nil {
+	if err := validate.Struct(menu); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if (menu.StartDate == nil) != (menu.EndDate == nil) {
+		c.Error(apperrors.BadRequest("a menu has both a start and an end date or neither"))
+		return
+	}
+	if menu.StartDate != nil && (!sameTime(menu.StartDate, current.StartDate) || !sameTime(menu.EndDate, current.EndDate)) {
 		if !inTimeSpan(*menu.StartDate, *menu.EndDate, time.Now()) {
-			c.JSON(400, gin.H{"status": "fail", "message": "please retype the time"})
+			c.Error(apperrors.BadRequest("please retype the time"))
 			return
 		}
+	}
 
-		menuObj = append(menuObj, bson.E{Key: "start_date", Value: menu.StartDate})
-		menuObj = append(menuObj, bson.E{Key: "end_date", Value: menu.EndDate})
+	var err error
+	menu.Translations, err = helpers.PrepareTranslations(menu.Translations)
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
 
-		if menu.Name != "" {
-			menuObj = append(menuObj, bson.E{Key: "name", Value: menu.Name})
-		}
-		if menu.Category != "" {
-			menuObj = append(menuObj, bson.E{Key: "category", Value: menu.Category})
-		}
-		menu.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		menuObj = append(menuObj, bson.E{Key: "updated_at", Value: menu.UpdatedAt})
+	menu.Version = current.Version + 1
+	menu.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
+	if err := replaceDocument(ctx, h.Menus, menu.ID, current.Version, menu); err != nil {
+		updateFailed(c, err, "menu not found")
+		return
+	}
+	setETag(c, menu.Version)
 
-		result, err := menuCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: menuObj}}, &opt)
-		if err != nil {
-			c.JSON(500, gin.H{
-				"status":  "fail",
-				"message": err.Error(),
-			})
-			return
-		}
+	c.JSON(200, gin.H{"status": "success", "data": menu})
+}
 
-		c.JSON(200, gin.H{"status": "success", "data": result})
-		return
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
-	c.JSON(400, gin.H{"status": "fail", "message": "cannot update the menu"})
+	return a.Equal(*b)
 }
 
 func inTimeSpan(start, end, check time.Time) bool {
 	return start.After(time.Now()) && end.After(start)
 }
+
+// GetMenuFoods lists the menu's foods, filtered like GetFoods by dietary
+// tags and excluded allergens, in the language the client asks for.
+func (h *Handler) GetMenuFoods(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	query, ok := listQuery(c, foodListFields, "name")
+	if !ok {
+		return
+	}
+	chain, ok := languageChain(c)
+	if !ok {
+		return
+	}
+
+	menu := models.Menu{}
+	if err := h.Menus.FindByID(ctx, c.Param("id"), &menu); err != nil {
+		c.Error(apperrors.NotFound("menu not found"))
+		return
+	}
+
+	filter, err := foodFilter(c)
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	filter = append(filter, bson.E{Key: "menu_id", Value: menu.MenuId})
+	query.Filter = withoutDeleted(c, append(query.Filter, filter...))
+	if query.PageStages, _, ok = expandQuery(c, "food"); !ok {
+		return
+	}
+
+	list(ctx, c, h.Foods, query, helpers.LocalizeStage(chain))
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func menuRoutes(app *gin.Engine, h *Handler) {
+	app.GET("/menus/:id", h.GetMenu)
+	app.POST("/menus", h.CreateMenu)
+	app.DELETE("/menus/:id", h.DeleteMenu)
+	app.POST("/menus/:id/restore", h.RestoreMenu)
+}
+
+func TestCreateMenuRejectsInvalidMenu(t *testing.T) {
+	app, _ := newTestApp(menuRoutes)
+
+	expectStatus(t, serve(app, http.MethodPost, "/menus", `{"name":"Lunch"}`), http.StatusBadRequest, nil)
+	expectStatus(t, serve(app, http.MethodPost, "/menus", `{"name":`), http.StatusBadRequest, nil)
+}
+
+func TestGetMenuNotFound(t *testing.T) {
+	app, _ := newTestApp(menuRoutes)
+
+	expectStatus(t, serve(app, http.MethodGet, "/menus/missing", ""), http.StatusNotFound, nil)
+}
+
+func TestMenuSoftDelete(t *testing.T) {
+	app, _ := newTestApp(menuRoutes)
+
+	menu := models.Menu{}
+	expectStatus(t, serve(app, http.MethodPost, "/menus", `{"name":"Lunch","category":"MAIN"}`), http.StatusCreated, &menu)
+
+	deleted := models.Menu{}
+	expectStatus(t, serve(app, http.MethodDelete, "/menus/"+menu.MenuId, ""), http.StatusOK, &deleted)
+	if deleted.DeletedAt == nil || deleted.Version != 1 {
+		t.Errorf("deleted menu has deleted_at %v at version %d, want a time at version 1", deleted.DeletedAt, deleted.Version)
+	}
+	expectStatus(t, serve(app, http.MethodDelete, "/menus/"+menu.MenuId, ""), http.StatusConflict, nil)
+
+	restored := models.Menu{}
+	expectStatus(t, serve(app, http.MethodPost, "/menus/"+menu.MenuId+"/restore", ""), http.StatusOK, &restored)
+	if restored.DeletedAt != nil {
+		t.Errorf("restored menu is still deleted at %v", restored.DeletedAt)
+	}
+	expectStatus(t, serve(app, http.MethodPost, "/menus/"+menu.MenuId+"/restore", ""), http.StatusConflict, nil)
+
+	expectStatus(t, serve(app, http.MethodDelete, "/menus/missing", ""), http.StatusNotFound, nil)
+}
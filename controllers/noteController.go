@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type noteTarget struct {
+	collection repositories.Collection
+	idField    string
+}
+
+// noteTargets tells where the record a note is attached to lives. Entity
+// types without a collection of their own are accepted as they are.
+func (h *Handler) noteTargets() map[string]noteTarget {
+	return map[string]noteTarget{
+		models.NoteEntityOrder:    {h.Orders, "order_id"},
+		models.NoteEntityTable:    {h.Tables, "table_id"},
+		models.NoteEntityFood:     {h.Foods, "food_id"},
+		models.NoteEntityCustomer: {h.Customers, "customer_id"},
+	}
+}
+
+func (h *Handler) GetNotes(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	query, ok := listQuery(c, noteListFields, "-pinned,-created_at")
+	if !ok {
+		return
+	}
+
+	list(ctx, c, h.Notes, query)
+}
+
+func (h *Handler) GetNote(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	noteId := c.Param("id")
+	note := models.Note{}
+
+	if err := h.Notes.FindByID(ctx, noteId, &note); err != nil {
+		c.Error(apperrors.NotFound("note not found"))
+		return
+	}
+
+	setETag(c, note.Version)
+	c.JSON(200, gin.H{"status": "success", "data": note})
+}
+
+func (h *Handler) CreateNote(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	note := models.Note{}
+	if err := c.ShouldBindJSON(&note); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := validate.Struct(note); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := h.checkNoteTarget(ctx, note.EntityType, note.EntityId); err != nil {
+		c.Error(err)
+		return
+	}
+
+	pinned := note.Pinned != nil && *note.Pinned
+	note.Pinned = &pinned
+	if note.Category == "" {
+		note.Category = "GENERAL"
+	}
+	note.AuthorId = signedInUser(c)
+	note.UpdatedBy = note.AuthorId
+	note.ID = primitive.NewObjectID()
+	note.NoteId = note.ID.Hex()
+	note.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	note.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if _, err := h.Notes.InsertOne(ctx, note); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("failed to create note: %w", err)))
+		return
+	}
+
+	c.JSON(201, gin.H{"status": "success", "data": note})
+}
+
+func (h *Handler) UpdateNote(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	noteId := c.Param("id")
+	note := models.Note{}
+
+	if err := c.ShouldBindJSON(&note); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	current := models.Note{}
+	if err := h.Notes.FindByID(ctx, noteId, &current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("note not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
+
+	noteObj := primitive.D{}
+
+	if note.Title != "" {
+		if err := validate.Var(note.Title, "max=100"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		noteObj = append(noteObj, bson.E{Key: "title", Value: note.Title})
+	}
+	if note.Text != "" {
+		if err := validate.Var(note.Text, "max=2000"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		noteObj = append(noteObj, bson.E{Key: "text", Value: note.Text})
+	}
+	if note.Category != "" {
+		if err := validate.Var(note.Category, "eq=GENERAL|eq=ALLERGY|eq=VIP|eq=HANDOVER"); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		noteObj = append(noteObj, bson.E{Key: "category", Value: note.Category})
+	}
+	if note.Pinned != nil {
+		noteObj = append(noteObj, bson.E{Key: "pinned", Value: note.Pinned})
+	}
+
+	note.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	noteObj = append(noteObj, bson.E{Key: "updated_by", Value: signedInUser(c)})
+	noteObj = append(noteObj, bson.E{Key: "updated_at", Value: note.UpdatedAt})
+
+	filter := bson.D{{Key: "_id", Value: current.ID}, versionFilter(current.Version)}
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	updated := models.Note{}
+	err := h.Notes.FindOneAndUpdate(ctx, filter, bson.D{{Key: "$set", Value: noteObj}, bumpVersion}, opt).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		err = missingOrChanged(ctx, h.Notes, current.ID)
+	}
+	if err != nil {
+		updateFailed(c, err, "note not found")
+		return
+	}
+	setETag(c, updated.Version)
+
+	c.JSON(200, gin.H{"status": "success", "data": updated})
+}
+
+func (h *Handler) DeleteNote(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	noteId := c.Param("id")
+
+	result, err := h.Notes.DeleteOne(ctx, bson.D{{Key: "note_id", Value: noteId}})
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.Error(apperrors.NotFound("note not found"))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": result})
+}
+
+// checkNoteTarget makes sure the record a note is attached to exists.
+func (h *Handler) checkNoteTarget(ctx context.Context, entityType string, entityId string) error {
+	target, ok := h.noteTargets()[entityType]
+	if !ok {
+		return nil
+	}
+
+	count, err := target.collection.CountDocuments(ctx, bson.D{{Key: target.idField, Value: entityId}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperrors.NotFound("cannot attach a note to a missing " + entityType)
+	}
+
+	return nil
+}
+
+// notesFor returns the notes attached to a record, pinned notes first, for
+// inclusion in the record's detail response.
+func (h *Handler) notesFor(ctx context.Context, entityType string, entityId string) ([]models.Note, error) {
+	filter := bson.D{
+		{Key: "entity_type", Value: entityType},
+		{Key: "entity_id", Value: entityId},
+	}
+	opt := options.Find().SetSort(bson.D{{Key: "pinned", Value: -1}, {Key: "created_at", Value: -1}})
+
+	cursor, err := h.Notes.Find(ctx, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := []models.Note{}
+	err = cursor.All(ctx, &notes)
+	return notes, err
+}
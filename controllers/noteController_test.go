@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func noteRoutes(app *gin.Engine, h *Handler) {
+	app.GET("/notes/:id", h.GetNote)
+	app.POST("/notes", h.CreateNote)
+	app.PATCH("/notes/:id", h.UpdateNote)
+	app.DELETE("/notes/:id", h.DeleteNote)
+}
+
+func TestNoteLifecycle(t *testing.T) {
+	app, _ := newTestApp(noteRoutes)
+
+	created := models.Note{}
+	response := serve(app, http.MethodPost, "/notes", `{"title":"Window seat","text":"Guests asked for the window","entity_type":"RESERVATION","entity_id":"r1"}`)
+	expectStatus(t, response, http.StatusCreated, &created)
+	if created.Category != "GENERAL" || created.Pinned == nil || *created.Pinned {
+		t.Errorf("new note is %q and pinned %v, want GENERAL and unpinned", created.Category, created.Pinned)
+	}
+	if created.AuthorId != nil {
+		t.Errorf("note of an unknown user names author %q", *created.AuthorId)
+	}
+
+	response = serve(app, http.MethodGet, "/notes/"+created.NoteId, "")
+	expectStatus(t, response, http.StatusOK, nil)
+	if etag := response.Header().Get("ETag"); etag != `"0"` {
+		t.Errorf("ETag %s, want \"0\"", etag)
+	}
+
+	patch := `{"pinned":true}`
+	expectStatus(t, serve(app, http.MethodPatch, "/notes/"+created.NoteId, patch), http.StatusPreconditionRequired, nil)
+
+	updated := models.Note{}
+	response = serve(app, http.MethodPatch, "/notes/"+created.NoteId, patch, "If-Match", `"0"`)
+	expectStatus(t, response, http.StatusOK, &updated)
+	if updated.Pinned == nil || !*updated.Pinned || updated.Version != 1 {
+		t.Errorf("updated note is pinned %v at version %d, want pinned at version 1", updated.Pinned, updated.Version)
+	}
+	if updated.Title != created.Title {
+		t.Errorf("title %q changed to %q by an update leaving it out", created.Title, updated.Title)
+	}
+
+	expectStatus(t, serve(app, http.MethodPatch, "/notes/"+created.NoteId, patch, "If-Match", `"0"`), http.StatusPreconditionFailed, nil)
+
+	expectStatus(t, serve(app, http.MethodDelete, "/notes/"+created.NoteId, ""), http.StatusOK, nil)
+	expectStatus(t, serve(app, http.MethodGet, "/notes/"+created.NoteId, ""), http.StatusNotFound, nil)
+	expectStatus(t, serve(app, http.MethodDelete, "/notes/"+created.NoteId, ""), http.StatusNotFound, nil)
+}
+
+func TestCreateNoteChecksTarget(t *testing.T) {
+	app, _ := newTestApp(noteRoutes)
+
+	body := `{"title":"Allergy","text":"No peanuts","entity_type":"ORDER","entity_id":"missing"}`
+	expectStatus(t, serve(app, http.MethodPost, "/notes", body), http.StatusNotFound, nil)
+
+	body = `{"title":"Allergy","text":"No peanuts","entity_type":"KITCHEN","entity_id":"k1"}`
+	expectStatus(t, serve(app, http.MethodPost, "/notes", body), http.StatusBadRequest, nil)
+}
@@ -2,193 +2,466 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
 	"github.com/RahulMj21/mongo-restaurant-management/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var ordersCollection = database.OpenCollection(database.Client, "order")
-
-func GetOrders(c *gin.Context) {
+func (h *Handler) GetOrders(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	cursor, err := ordersCollection.Find(ctx, bson.M{})
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	query, ok := listQuery(c, orderListFields, "-order_date")
+	if !ok {
 		return
 	}
-
-	var orders []primitive.M
-
-	if err := cursor.All(ctx, &orders); err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	query.Filter = withoutDeleted(c, query.Filter)
+	if query.PageStages, _, ok = expandQuery(c, "order"); !ok {
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"status": "success",
-		"data":   orders,
-	})
+	list(ctx, c, h.Orders, query)
 }
 
-func GetOrder(c *gin.Context) {
+func (h *Handler) GetOrder(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
+	stages, expanded, ok := expandQuery(c, "order")
+	if !ok {
+		return
+	}
+
 	orderId := c.Param("id")
 	order := models.Order{}
 
 	filter := bson.D{{Key: "order_id", Value: orderId}}
-	if err := ordersCollection.FindOne(ctx, filter).Decode(&order); err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	if err := h.Orders.FindOne(ctx, filter).Decode(&order); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("order not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
+	notes, err := h.notesFor(ctx, models.NoteEntityOrder, order.OrderId)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	order.Notes = notes
+
+	data, err := expandOne(ctx, c, h.Orders, order.ID, stages, expanded, order)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	setETag(c, order.Version)
 	c.JSON(200, gin.H{
 		"status": "success",
-		"data":   order,
+		"data":   data,
 	})
 }
 
-func CreateOrder(c *gin.Context) {
+func (h *Handler) CreateOrder(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	order := models.Order{}
 	table := models.Table{}
 
-	if err := c.BindJSON(&order); err != nil {
-		c.JSON(400, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	if err := c.ShouldBindJSON(&order); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	err := validate.Struct(order)
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	if err := prepareOrderType(&order); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := validate.Struct(order); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	if order.TableId != nil {
-		if err := tableCollection.FindOne(ctx, bson.D{{Key: "table_id", Value: order.TableId}}).Decode(&table); err != nil {
-			c.JSON(400, gin.H{
-				"status":  "fail",
-				"message": err.Error(),
-			})
+		if err := h.Tables.FindByID(ctx, *order.TableId, &table); err != nil || table.DeletedAt != nil {
+			c.Error(apperrors.BadRequest("table not found"))
 			return
 		}
 	}
 
+	if err := h.checkCustomer(ctx, order.CustomerId); err != nil {
+		c.Error(err)
+		return
+	}
+
 	order.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	order.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	order.ID = primitive.NewObjectID()
 	order.OrderId = order.ID.Hex()
 
-	insertedItem, err := ordersCollection.InsertOne(ctx, order)
+	insertedItem, err := h.Orders.InsertOne(ctx, order)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	if err := h.recordVisit(ctx, order.CustomerId, order.OrderDate); err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
 	newItem := models.Order{}
 
-	err = ordersCollection.FindOne(ctx, bson.D{{Key: "_id", Value: insertedItem.InsertedID}}).Decode(&newItem)
+	err = h.Orders.FindOne(ctx, bson.D{{Key: "_id", Value: insertedItem.InsertedID}}).Decode(&newItem)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Internal(err))
+		return
 	}
+
+	setETag(c, newItem.Version)
+	c.JSON(201, gin.H{"status": "success", "data": newItem})
 }
 
-func UpdateOrder(c *gin.Context) {
+// UpdateOrder replaces the order on PUT and merge patches it on PATCH. The
+// fields of an order type only make sense together, so they are checked on
+// the order as it will be stored. Drivers are assigned on their own route.
+func (h *Handler) UpdateOrder(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	table := models.Table{}
-	order := models.Order{}
-
+	current := models.Order{}
 	orderId := c.Param("id")
 
-	if err := c.BindJSON(&order); err != nil {
-		c.JSON(400, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+	if err := h.Orders.FindByID(ctx, orderId, &current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("order not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	var orderObj primitive.D
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
 
+	order := models.Order{}
+	if err := readUpdate(c, current, &order); err != nil {
+		updateBodyFailed(c, err)
+		return
+	}
+	order.ID, order.OrderId, order.CreatedAt = current.ID, current.OrderId, current.CreatedAt
+	order.DeletedAt, order.DeletedBy = current.DeletedAt, current.DeletedBy
+	order.DriverId, order.DriverAssignedAt = current.DriverId, current.DriverAssignedAt
+	order.BilledAt = current.BilledAt
+	order.Notes = nil
+
+	if err := prepareOrderType(&order); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if err := validate.Struct(order); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
 	if order.TableId != nil {
-		err := tableCollection.FindOne(ctx, bson.D{{Key: "table_id", Value: order.TableId}}).Decode(&table)
-		if err != nil {
-			c.JSON(400, gin.H{
-				"status":  "fail",
-				"message": err.Error(),
-			})
+		if err := h.Tables.FindByID(ctx, *order.TableId, &table); err != nil || (table.DeletedAt != nil && !sameString(order.TableId, current.TableId)) {
+			c.Error(apperrors.BadRequest("table not found"))
 			return
 		}
-		orderObj = append(orderObj, bson.E{Key: "table_id", Value: table.TableId})
+	}
+	if err := h.checkCustomer(ctx, order.CustomerId); err != nil {
+		c.Error(err)
+		return
 	}
 
+	order.Version = current.Version + 1
 	order.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	orderObj = append(orderObj, bson.E{Key: "updated_at", Value: order.UpdatedAt})
 
-	upsert := true
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+	if err := replaceDocument(ctx, h.Orders, order.ID, current.Version, order); err != nil {
+		updateFailed(c, err, "order not found")
+		return
 	}
-	filter := bson.D{{Key: "order_id", Value: orderId}}
+	setETag(c, order.Version)
+
+	c.JSON(200, gin.H{"status": "success", "data": order})
+}
+
+type DriverBody struct {
+	DriverId string `json:"driver_id" validate:"required"`
+}
+
+// AssignOrderDriver hands a delivery order to a driver, who has to be a user
+// of the system.
+func (h *Handler) AssignOrderDriver(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	orderId := c.Param("id")
+	body := DriverBody{}
+	order := models.Order{}
+	user := models.User{}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if err := validate.Struct(body); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := h.Orders.FindByID(ctx, orderId, &order); err != nil {
+		c.Error(apperrors.NotFound("order not found"))
+		return
+	}
+	if order.OrderType != models.OrderTypeDelivery {
+		c.Error(apperrors.BadRequest("only delivery orders have a driver"))
+		return
+	}
+
+	if err := h.Users.FindByID(ctx, body.DriverId, &user); err != nil {
+		c.Error(apperrors.NotFound("driver not found"))
+		return
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "driver_id", Value: user.UserId},
+		{Key: "driver_assigned_at", Value: now},
+		{Key: "updated_at", Value: now},
+	}}, bumpVersion}
 
-	result, err := ordersCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: orderObj}}, &opt)
+	result, err := h.Orders.UpdateOne(ctx, bson.D{{Key: "order_id", Value: orderId}}, update)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "fail",
-			"message": err.Error(),
-		})
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"status": "success",
-		"data":   result,
-	})
+	c.JSON(200, gin.H{"status": "success", "data": result})
 }
 
-func OrderItemOrderCreator(order models.Order) string {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+// prepareOrderType defaults the order to dine-in and makes sure only
+// delivery orders carry a delivery fee.
+func prepareOrderType(order *models.Order) error {
+	if order.OrderType == "" {
+		order.OrderType = models.OrderTypeDineIn
+	}
+
+	if order.DeliveryFee != nil {
+		if order.OrderType != models.OrderTypeDelivery {
+			return errors.New("only delivery orders have a delivery fee")
+		}
+		deliveryFee := helpers.RoundPrice(*order.DeliveryFee)
+		order.DeliveryFee = &deliveryFee
+	}
 
+	return nil
+}
+
+// stampNewOrder gives an order about to be stored its ids and timestamps.
+func stampNewOrder(order *models.Order) {
 	order.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	order.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	order.ID = primitive.NewObjectID()
 	order.OrderId = order.ID.Hex()
+}
+
+// insertOrderWithItems stores the items, and the order they belong to when
+// it is new, all or nothing. A transaction rolls back a failed write by
+// itself. Deployments without transactions delete whatever was written.
+func (h *Handler) insertOrderWithItems(ctx context.Context, order *models.Order, orderItems []models.OrderItem) error {
+	return h.Transactions.WithTransaction(ctx, func(sessCtx context.Context) error {
+		compensate := !h.Transactions.SupportsTransactions(sessCtx)
+		undo := func() {
+			if !compensate {
+				return
+			}
+			ids := bson.A{}
+			for _, orderItem := range orderItems {
+				ids = append(ids, orderItem.OrderItemId)
+			}
+			if _, err := h.OrderItems.DeleteMany(ctx, bson.D{{Key: "order_item_id", Value: bson.D{{Key: "$in", Value: ids}}}}); err != nil {
+				log.Printf("cannot delete the items of a failed order: %v", err)
+			}
+			if order == nil {
+				return
+			}
+			if _, err := h.Orders.DeleteOne(ctx, bson.D{{Key: "order_id", Value: order.OrderId}}); err != nil {
+				log.Printf("cannot delete failed order %s: %v", order.OrderId, err)
+			}
+		}
+
+		if order != nil {
+			if _, err := h.Orders.InsertOne(sessCtx, order); err != nil {
+				return err
+			}
+		}
+
+		if len(orderItems) > 0 {
+			orderItemsToBeInserted := []interface{}{}
+			for _, orderItem := range orderItems {
+				orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+			}
+			// an ordered insert may have stored the items before the failing one
+			if _, err := h.OrderItems.InsertMany(sessCtx, orderItemsToBeInserted); err != nil {
+				undo()
+				return err
+			}
+		}
+
+		if order != nil {
+			if err := h.recordVisit(sessCtx, order.CustomerId, order.OrderDate); err != nil {
+				undo()
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type OrderCourseView struct {
+	Course                     string     `json:"course"`
+	Status                     string     `json:"status"`
+	Items                      int        `json:"items"`
+	HeldItems                  int        `json:"held_items"`
+	FiredAt                    *time.Time `json:"fired_at"`
+	SecondsSincePreviousCourse *float64   `json:"seconds_since_previous_course"`
+}
+
+// GetOrderCourses shows which courses of the order are held or fired, when
+// each was fired and how long the kitchen waited between courses.
+func (h *Handler) GetOrderCourses(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	orderId := c.Param("id")
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "order_id", Value: orderId},
+		{Key: "course", Value: bson.D{{Key: "$ne", Value: nil}}},
+		{Key: "item_type", Value: bson.D{{Key: "$ne", Value: models.OrderItemTypeBundle}}},
+		approvedItems,
+	}}}
+	groupStage := bson.D{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: "$course"},
+		{Key: "items", Value: bson.D{{Key: "$sum", Value: 1}}},
+		{Key: "held_items", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{"$fire_status", models.FireStatusHeld}}}, 1, 0,
+		}}}}}},
+		{Key: "fired_at", Value: bson.D{{Key: "$min", Value: "$fired_at"}}},
+	}}}
+
+	cursor, err := h.OrderItems.Aggregate(ctx, mongo.Pipeline{matchStage, groupStage})
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
 
-	ordersCollection.InsertOne(ctx, order)
+	groups := []struct {
+		Course    string     `bson:"_id"`
+		Items     int        `bson:"items"`
+		HeldItems int        `bson:"held_items"`
+		FiredAt   *time.Time `bson:"fired_at"`
+	}{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return helpers.CourseRank(&groups[i].Course) < helpers.CourseRank(&groups[j].Course)
+	})
+
+	courses := []OrderCourseView{}
+	var previousFiredAt *time.Time
+	for _, group := range groups {
+		course := OrderCourseView{
+			Course:    group.Course,
+			Status:    models.FireStatusFired,
+			Items:     group.Items,
+			HeldItems: group.HeldItems,
+			FiredAt:   group.FiredAt,
+		}
+		if group.HeldItems == group.Items {
+			course.Status = models.FireStatusHeld
+		}
+		if group.FiredAt != nil {
+			if previousFiredAt != nil {
+				seconds := group.FiredAt.Sub(*previousFiredAt).Seconds()
+				course.SecondsSincePreviousCourse = &seconds
+			}
+			previousFiredAt = group.FiredAt
+		}
+		courses = append(courses, course)
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": courses})
+}
+
+func (h *Handler) FireOrderCourse(c *gin.Context) {
+	h.setCourseFireStatus(c, models.FireStatusFired)
+}
+
+func (h *Handler) HoldOrderCourse(c *gin.Context) {
+	h.setCourseFireStatus(c, models.FireStatusHeld)
+}
+
+// setCourseFireStatus fires or holds every item of one course of an order.
+// Firing stamps the items with the time they were sent to the kitchen.
+func (h *Handler) setCourseFireStatus(c *gin.Context, fireStatus string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	orderId := c.Param("id")
+	course := strings.ToUpper(c.Param("course"))
+	if helpers.CourseRank(&course) == 0 {
+		c.Error(apperrors.BadRequest("course must be one of STARTER, MAIN or DESSERT"))
+		return
+	}
+
+	order := models.Order{}
+	if err := h.Orders.FindByID(ctx, orderId, &order); err != nil {
+		c.Error(apperrors.NotFound("order not found"))
+		return
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	var firedAt *time.Time
+	if fireStatus == models.FireStatusFired {
+		firedAt = &now
+	}
+
+	filter := bson.D{
+		{Key: "order_id", Value: orderId},
+		{Key: "course", Value: course},
+		{Key: "fire_status", Value: bson.D{{Key: "$ne", Value: fireStatus}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "fire_status", Value: fireStatus},
+		{Key: "fired_at", Value: firedAt},
+		{Key: "updated_at", Value: now},
+	}}, bumpVersion}
+
+	result, err := h.OrderItems.UpdateMany(ctx, filter, update)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
 
-	return order.OrderId
+	c.JSON(200, gin.H{"status": "success", "data": result})
 }
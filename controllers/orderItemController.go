@@ -2,84 +2,128 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"time"
 
-	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
 	"github.com/RahulMj21/mongo-restaurant-management/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type OrderItemPack struct {
-	TableId    *string
-	OrderItems []models.OrderItem
+	TableId         *string
+	CustomerId      *string
+	OrderType       string
+	PickupTime      *time.Time
+	Contact         *models.OrderContact
+	DeliveryAddress *models.Address
+	DeliveryFee     *float64
+	OrderItems      []models.OrderItem
+	Bundles         []models.BundleSelection
 }
 
-var orderItemCollection = database.OpenCollection(database.Client, "order_item")
-
-func GetOrderItems(c *gin.Context) {
+func (h *Handler) GetOrderItems(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	cursor, err := orderItemCollection.Find(ctx, bson.M{})
-	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+	query, ok := listQuery(c, orderItemListFields, "-created_at")
+	if !ok {
 		return
 	}
-
-	allOrderItems := []primitive.M{}
-	if err := cursor.All(ctx, &allOrderItems); err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+	if query.PageStages, _, ok = expandQuery(c, "order_item"); !ok {
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "success", "data": allOrderItems})
+	list(ctx, c, h.OrderItems, query)
 }
 
-func GetOrderItem(c *gin.Context) {
+func (h *Handler) GetOrderItem(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	orderItemId := c.Param("id")
 	if orderItemId == "" {
-		c.JSON(400, gin.H{"status": "fail", "message": "order_item id cannot be empty"})
+		c.Error(apperrors.BadRequest("order_item id cannot be empty"))
+		return
+	}
+
+	stages, expanded, ok := expandQuery(c, "order_item")
+	if !ok {
 		return
 	}
 
 	orderItem := models.OrderItem{}
 
-	err := orderItemCollection.FindOne(ctx, bson.D{{Key: "order_item_id", Value: orderItemId}}).Decode(&orderItem)
+	err := h.OrderItems.FindByID(ctx, orderItemId, &orderItem)
+	if err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("order item not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot find the order_item: %w", err)))
+		return
+	}
+
+	data, err := expandOne(ctx, c, h.OrderItems, orderItem.ID, stages, expanded, orderItem)
 	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "cannot find the order_item"})
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "success", "data": orderItem})
+	setETag(c, orderItem.Version)
+	c.JSON(200, gin.H{"status": "success", "data": data})
 }
 
-func GetOrderItemsByOrderId(c *gin.Context) {
+func (h *Handler) GetOrderItemsByOrderId(c *gin.Context) {
 	orderID := c.Param("order_id")
 	if orderID == "" {
-		c.JSON(400, gin.H{"status": "fail", "message": "order id cannot be empty"})
+		c.Error(apperrors.BadRequest("order id cannot be empty"))
 		return
 	}
-	allOrderItems, err := ItemsByOrderId(orderID)
+	var allOrderItems []primitive.M
+	var err error
+	if c.Query("group_by") == "seat" {
+		allOrderItems, err = h.ItemsByOrderIdPerSeat(orderID)
+	} else {
+		allOrderItems, err = h.ItemsByOrderId(orderID)
+	}
 	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "cannot get order items"})
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get order items: %w", err)))
 		return
 	}
 
 	c.JSON(200, gin.H{"status": "success", "data": allOrderItems})
 }
 
-func ItemsByOrderId(id string) ([]primitive.M, error) {
+func (h *Handler) ItemsByOrderId(id string) ([]primitive.M, error) {
+	return h.itemsByOrder(id, nil, false)
+}
+
+// ItemsByOrderIdAndSeats totals only the items ordered for the given seats.
+// Seat 0 stands for the items that were not ordered for a particular seat.
+func (h *Handler) ItemsByOrderIdAndSeats(id string, seats []int) ([]primitive.M, error) {
+	return h.itemsByOrder(id, seats, false)
+}
+
+// ItemsByOrderIdPerSeat totals the order once per seat, so food runners know
+// who gets what and the bill can be split by seat.
+func (h *Handler) ItemsByOrderIdPerSeat(id string) ([]primitive.M, error) {
+	return h.itemsByOrder(id, nil, true)
+}
+
+func (h *Handler) itemsByOrder(id string, seats []int, groupBySeat bool) ([]primitive.M, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "order_id", Value: id}}}}
+	match := bson.D{{Key: "order_id", Value: id}, approvedItems}
+	if seats != nil {
+		match = append(match, bson.E{Key: "$or", Value: seatFilter(seats)})
+	}
+
+	matchStage := bson.D{{Key: "$match", Value: match}}
 	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{
 		{Key: "from", Value: "food"},
 		{Key: "localField", Value: "food_id"},
@@ -91,6 +135,17 @@ func ItemsByOrderId(id string) ([]primitive.M, error) {
 		{Key: "preserveNullAndEmptyArrays", Value: true},
 	}}}
 
+	lookupBundleStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "bundle"},
+		{Key: "localField", Value: "bundle_id"},
+		{Key: "foreignField", Value: "bundle_id"},
+		{Key: "as", Value: "bundle"},
+	}}}
+	unwindBundleStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$bundle"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+
 	lookupOrderStage := bson.D{{Key: "$lookup", Value: bson.D{
 		{Key: "from", Value: "order"},
 		{Key: "localField", Value: "order_id"},
@@ -113,23 +168,59 @@ func ItemsByOrderId(id string) ([]primitive.M, error) {
 		{Key: "preserveNullAndEmptyArrays", Value: true},
 	}}}
 
+	// Items stored before portion sizes were split out carry the size in
+	// `quantity` and a client supplied unit price, so both fall back to the
+	// values those items were always billed with.
+	quantity := bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$isNumber", Value: "$quantity"}}, "$quantity", 1,
+	}}}
+	unitPrice := bson.D{{Key: "$ifNull", Value: bson.A{
+		"$unit_price",
+		bson.D{{Key: "$add", Value: bson.A{
+			"$food.price",
+			bson.D{{Key: "$ifNull", Value: bson.A{"$modifiers_price", 0}}},
+		}}},
+	}}}
+
 	projectStage := bson.D{{Key: "$project", Value: bson.D{
 		{Key: "_id", Value: 0},
-		{Key: "amount", Value: "$food.price"},
+		{Key: "amount", Value: bson.D{{Key: "$multiply", Value: bson.A{unitPrice, quantity}}}},
 		{Key: "total_count", Value: 1},
-		{Key: "food_name", Value: "$food.name"},
-		{Key: "food_image", Value: "$food.image"},
+		{Key: "food_name", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$food.name", "$bundle.name"}}}},
+		{Key: "food_image", Value: "$food.food_image"},
+		{Key: "image_id", Value: "$food.image_id"},
 		{Key: "table_number", Value: "$table.table_number"},
-		{Key: "price", Value: "$food.price"},
-		{Key: "quantity", Value: 1},
+		{Key: "price", Value: unitPrice},
+		{Key: "item_type", Value: 1},
+		{Key: "bundle_id", Value: 1},
+		{Key: "order_item_id", Value: 1},
+		{Key: "parent_order_item_id", Value: 1},
+		{Key: "portion_size", Value: 1},
+		{Key: "quantity", Value: quantity},
+		{Key: "modifiers", Value: 1},
+		{Key: "modifiers_price", Value: 1},
+		{Key: "special_instructions", Value: 1},
+		{Key: "course", Value: 1},
+		{Key: "fire_status", Value: 1},
+		{Key: "fired_at", Value: 1},
+		{Key: "seat_number", Value: 1},
 	}}}
-	groupStage := bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: bson.D{
+
+	groupId := bson.D{
 		{Key: "order_id", Value: "$order_id"},
 		{Key: "table_id", Value: "$table_id"},
 		{Key: "table_number", Value: "$table_number"},
-	}},
+	}
+	if groupBySeat {
+		groupId = append(groupId, bson.E{Key: "seat_number", Value: "$seat_number"})
+	}
+
+	groupStage := bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: groupId},
 		{Key: "payment_due", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
-		{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		// bundle components are billed through their bundle, so they are not counted
+		{Key: "total_count", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{"$item_type", models.OrderItemTypeBundleComponent}}}, 0, 1,
+		}}}}}},
 		{Key: "order_items", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
 	}}}
 
@@ -138,14 +229,19 @@ func ItemsByOrderId(id string) ([]primitive.M, error) {
 		{Key: "payment_due", Value: 1},
 		{Key: "total_count", Value: 1},
 		{Key: "table_number", Value: "$_id.table_number"},
+		{Key: "seat_number", Value: "$_id.seat_number"},
 		{Key: "order_items", Value: 1},
 	}}}
 
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "seat_number", Value: 1}}}}
+
 	orderItems := []primitive.M{}
-	cursor, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{
+	cursor, err := h.OrderItems.Aggregate(ctx, mongo.Pipeline{
 		matchStage,
 		lookupStage,
 		unwindStage,
+		lookupBundleStage,
+		unwindBundleStage,
 		lookupOrderStage,
 		unwindOrderStage,
 		lookupTableStage,
@@ -153,6 +249,7 @@ func ItemsByOrderId(id string) ([]primitive.M, error) {
 		projectStage,
 		groupStage,
 		projectStage2,
+		sortStage,
 	})
 
 	if err != nil {
@@ -164,91 +261,528 @@ func ItemsByOrderId(id string) ([]primitive.M, error) {
 	return orderItems, err
 }
 
-func CreateOrderItem(c *gin.Context) {
+func (h *Handler) CreateOrderItem(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	orderItemPack := OrderItemPack{}
 	order := models.Order{}
-	if err := c.BindJSON(&orderItemPack); err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": err.Error()})
+	if err := c.ShouldBindJSON(&orderItemPack); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	seats := []int{}
+	for _, orderItem := range orderItemPack.OrderItems {
+		if orderItem.SeatNumber != nil {
+			seats = append(seats, *orderItem.SeatNumber)
+		}
+	}
+	for _, selection := range orderItemPack.Bundles {
+		if selection.SeatNumber != nil {
+			seats = append(seats, *selection.SeatNumber)
+		}
+	}
+	if err := h.checkSeats(ctx, orderItemPack.TableId, seats); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.checkCustomer(ctx, orderItemPack.CustomerId); err != nil {
+		c.Error(err)
 		return
 	}
 
 	order.OrderDate, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	order.TableId = orderItemPack.TableId
-	order_id := OrderItemOrderCreator(order)
+	order.CustomerId = orderItemPack.CustomerId
+	order.OrderType = orderItemPack.OrderType
+	order.PickupTime = orderItemPack.PickupTime
+	order.Contact = orderItemPack.Contact
+	order.DeliveryAddress = orderItemPack.DeliveryAddress
+	order.DeliveryFee = orderItemPack.DeliveryFee
+	if err := prepareOrderType(&order); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if err := validate.Struct(order); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if order.TableId != nil {
+		table := models.Table{}
+		if err := h.Tables.FindByID(ctx, *order.TableId, &table); err != nil || table.DeletedAt != nil {
+			c.Error(apperrors.BadRequest("table not found"))
+			return
+		}
+	}
+	if len(orderItemPack.OrderItems) == 0 && len(orderItemPack.Bundles) == 0 {
+		c.Error(apperrors.BadRequest("order needs at least one item"))
+		return
+	}
+	stampNewOrder(&order)
+	order_id := order.OrderId
 
-	orderItemsToBeInserted := []interface{}{}
+	orderItems := []models.OrderItem{}
 
 	for _, orderItem := range orderItemPack.OrderItems {
 		orderItem.OrderId = order_id
+		// bundles are ordered through the pack's Bundles, never as plain items
+		orderItem.ItemType = ""
+		orderItem.BundleId = nil
+		orderItem.BundleSlotId = ""
+		orderItem.ParentOrderItemId = nil
 		validationErr := validate.Struct(orderItem)
 		if validationErr != nil {
-			c.JSON(400, gin.H{"status": "fail", "message": validationErr.Error()})
+			c.Error(apperrors.Validation(validationErr))
+			return
+		}
+
+		if err := h.priceOrderItem(ctx, &orderItem); err != nil {
+			c.Error(err)
 			return
 		}
 		orderItem.ID = primitive.NewObjectID()
 		orderItem.OrderItemId = orderItem.ID.Hex()
 		orderItem.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		orderItem.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		num := toFixed(*orderItem.UnitPrice, 2)
-		orderItem.UnitPrice = &num
 
-		orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+		orderItems = append(orderItems, orderItem)
 	}
-	insertedItems, err := orderItemCollection.InsertMany(ctx, orderItemsToBeInserted)
-	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+
+	for _, selection := range orderItemPack.Bundles {
+		if err := validate.Struct(selection); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		bundleItems, err := h.bundleOrderItems(ctx, order_id, selection)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		orderItems = append(orderItems, bundleItems...)
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	helpers.AssignFiring(orderItems, 0, now)
+
+	if err := h.insertOrderWithItems(ctx, &order, orderItems); err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	c.JSON(201, gin.H{"status": "success", "data": insertedItems})
+	c.JSON(201, gin.H{"status": "success", "data": gin.H{
+		"order":       order,
+		"order_items": orderItems,
+	}})
 }
 
-func UpdateOrderItem(c *gin.Context) {
+// UpdateOrderItem replaces the item on PUT and merge patches it on PATCH.
+// Only what the guest chose can change: the food, portion, modifiers,
+// quantity, seat and instructions. Prices, approval and firing stay with
+// their own routes.
+func (h *Handler) UpdateOrderItem(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	orderItemId := c.Param("id")
-	orderItem := models.OrderItem{}
-
 	if orderItemId == "" {
-		c.JSON(400, gin.H{"status": "fail", "message": "id cannot be empty"})
+		c.Error(apperrors.BadRequest("id cannot be empty"))
 		return
 	}
-	if err := c.BindJSON(&orderItem); err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": err.Error()})
+	current := models.OrderItem{}
+	if err := h.OrderItems.FindByID(ctx, orderItemId, &current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("cannot find the order_item"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	var orderItemObj primitive.D
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
 
-	if orderItem.UnitPrice != nil {
-		unitPrice := toFixed(*orderItem.UnitPrice, 2)
-		orderItemObj = append(orderItemObj, bson.E{Key: "unit_price", Value: &unitPrice})
+	next := models.OrderItem{}
+	if err := readUpdate(c, current, &next); err != nil {
+		updateBodyFailed(c, err)
+		return
 	}
-	if orderItem.FoodId != nil {
-		orderItemObj = append(orderItemObj, bson.E{Key: "food_id", Value: orderItem.FoodId})
+	orderItem := current
+	orderItem.FoodId = next.FoodId
+	orderItem.PortionSize = next.PortionSize
+	orderItem.Modifiers = next.Modifiers
+	orderItem.Quantity = next.Quantity
+	orderItem.SeatNumber = next.SeatNumber
+	orderItem.SpecialInstructions = next.SpecialInstructions
+
+	// a bundle is no food of its own, its components carry the foods
+	var err error
+	if orderItem.ItemType == models.OrderItemTypeBundle {
+		err = validate.StructExcept(orderItem, "FoodId")
+	} else {
+		err = validate.Struct(orderItem)
 	}
-	if orderItem.Quantity != nil {
-		orderItemObj = append(orderItemObj, bson.E{Key: "quantity", Value: orderItem.Quantity})
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if !sameInt(orderItem.SeatNumber, current.SeatNumber) && orderItem.SeatNumber != nil {
+		order := models.Order{}
+		if err := h.Orders.FindByID(ctx, current.OrderId, &order); err != nil {
+			c.Error(apperrors.NotFound("order not found"))
+			return
+		}
+		if err := h.checkSeats(ctx, order.TableId, []int{*orderItem.SeatNumber}); err != nil {
+			c.Error(err)
+			return
+		}
 	}
 
+	// The unit price depends on the food, its portion size and the modifiers,
+	// so it is worked out again whenever one changes.
+	foodChanged := !sameString(orderItem.FoodId, current.FoodId)
+	if foodChanged || !sameString(orderItem.PortionSize, current.PortionSize) || !sameModifiers(orderItem.Modifiers, current.Modifiers) {
+		if current.ItemType != "" {
+			c.Error(apperrors.BadRequest("bundle items cannot change food, size or modifiers, order the bundle again instead"))
+			return
+		}
+		if foodChanged && sameModifiers(orderItem.Modifiers, current.Modifiers) {
+			// modifiers of the old food mean nothing for the new one
+			orderItem.Modifiers = nil
+		}
+		if err := h.priceOrderItem(ctx, &orderItem); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	orderItem.Version = current.Version + 1
 	orderItem.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	orderItemObj = append(orderItemObj, bson.E{Key: "updated_at", Value: orderItem.UpdatedAt})
 
-	filter := bson.D{{Key: "order_item_id", Value: orderItemId}}
-	upsert := true
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+	if err := replaceDocument(ctx, h.OrderItems, orderItem.ID, current.Version, orderItem); err != nil {
+		updateFailed(c, err, "cannot find the order_item")
+		return
+	}
+	setETag(c, orderItem.Version)
+
+	// the components of a bundle are made as many times as the bundle is
+	// ordered and go to the seat the bundle was ordered for
+	if current.ItemType == models.OrderItemTypeBundle {
+		componentObj := primitive.D{
+			{Key: "quantity", Value: orderItem.Quantity},
+			{Key: "seat_number", Value: orderItem.SeatNumber},
+			{Key: "updated_at", Value: orderItem.UpdatedAt},
+		}
+		componentFilter := bson.D{{Key: "parent_order_item_id", Value: orderItemId}}
+		componentUpdate := bson.D{{Key: "$set", Value: componentObj}, bumpVersion}
+		if _, err := h.OrderItems.UpdateMany(ctx, componentFilter, componentUpdate); err != nil {
+			c.Error(apperrors.Internal(err))
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": orderItem})
+}
+
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameModifiers compares the options chosen, not the names and prices
+// copied from the food when they were.
+func sameModifiers(a, b []models.SelectedModifier) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ModifierGroupId != b[i].ModifierGroupId || a[i].OptionId != b[i].OptionId {
+			return false
+		}
+	}
+	return true
+}
+
+// GetPendingOrderItems lists the guest items waiting for a waiter's approval,
+// oldest first, with the table they were ordered from.
+func (h *Handler) GetPendingOrderItems(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "approval_status", Value: models.ApprovalPending}}}}
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}}}}
+	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "food"},
+		{Key: "localField", Value: "food_id"},
+		{Key: "foreignField", Value: "food_id"},
+		{Key: "as", Value: "food"},
+	}}}
+	unwindStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$food"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	lookupOrderStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "order"},
+		{Key: "localField", Value: "order_id"},
+		{Key: "foreignField", Value: "order_id"},
+		{Key: "as", Value: "order"},
+	}}}
+	unwindOrderStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$order"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	lookupTableStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "table"},
+		{Key: "localField", Value: "order.table_id"},
+		{Key: "foreignField", Value: "table_id"},
+		{Key: "as", Value: "table"},
+	}}}
+	unwindTableStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$table"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "order_item_id", Value: 1},
+		{Key: "order_id", Value: 1},
+		{Key: "table_number", Value: "$table.table_number"},
+		{Key: "seat_number", Value: 1},
+		{Key: "food_name", Value: "$food.name"},
+		{Key: "portion_size", Value: 1},
+		{Key: "quantity", Value: 1},
+		{Key: "unit_price", Value: 1},
+		{Key: "modifiers", Value: 1},
+		{Key: "special_instructions", Value: 1},
+		{Key: "course", Value: 1},
+		{Key: "created_at", Value: 1},
+	}}}
+
+	cursor, err := h.OrderItems.Aggregate(ctx, mongo.Pipeline{
+		matchStage,
+		sortStage,
+		lookupStage,
+		unwindStage,
+		lookupOrderStage,
+		unwindOrderStage,
+		lookupTableStage,
+		unwindTableStage,
+		projectStage,
+	})
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get pending order items: %w", err)))
+		return
+	}
+
+	pendingItems := []primitive.M{}
+	if err := cursor.All(ctx, &pendingItems); err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get pending order items: %w", err)))
+		return
 	}
 
-	result, err := orderItemCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: orderItemObj}}, &opt)
+	c.JSON(200, gin.H{"status": "success", "data": pendingItems})
+}
+
+// ApproveOrderItem lets a guest item through to the kitchen and the bill. A
+// fired item counts as fired from the moment it is approved, so it isn't
+// queued behind items the kitchen got while it was waiting.
+func (h *Handler) ApproveOrderItem(c *gin.Context) {
+	h.reviewOrderItem(c, models.ApprovalApproved)
+}
+
+// RejectOrderItem turns a guest item down, it is neither cooked nor billed.
+func (h *Handler) RejectOrderItem(c *gin.Context) {
+	h.reviewOrderItem(c, models.ApprovalRejected)
+}
+
+func (h *Handler) reviewOrderItem(c *gin.Context, approvalStatus string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "order_item_id", Value: c.Param("id")},
+		{Key: "approval_status", Value: models.ApprovalPending},
+	}
+	orderItem := models.OrderItem{}
+	if err := h.OrderItems.FindOne(ctx, filter).Decode(&orderItem); err != nil {
+		c.Error(apperrors.NotFound("no pending order item found"))
+		return
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	orderItemObj := primitive.D{
+		{Key: "approval_status", Value: approvalStatus},
+		{Key: "reviewed_by", Value: signedInUser(c)},
+		{Key: "reviewed_at", Value: now},
+		{Key: "updated_at", Value: now},
+	}
+	if approvalStatus == models.ApprovalApproved && orderItem.FireStatus == models.FireStatusFired {
+		orderItemObj = append(orderItemObj, bson.E{Key: "fired_at", Value: now})
+	}
+
+	result, err := h.OrderItems.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: orderItemObj}, bumpVersion})
 	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.Error(apperrors.Conflict("order item was reviewed in the meantime"))
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "fail", "data": result})
+	c.JSON(200, gin.H{"status": "success", "data": result})
+}
+
+// priceOrderItem resolves the item's modifiers against its food and sets the
+// unit price to the portion price plus the modifier price deltas.
+func (h *Handler) priceOrderItem(ctx context.Context, orderItem *models.OrderItem) error {
+	food := models.Food{}
+	if orderItem.FoodId == nil {
+		return apperrors.BadRequest("food not found")
+	}
+	if err := h.Foods.FindByID(ctx, *orderItem.FoodId, &food); err != nil || food.DeletedAt != nil {
+		return apperrors.BadRequest("food not found")
+	}
+
+	modifiers, modifiersPrice, err := helpers.ResolveModifiers(food, orderItem.Modifiers)
+	if err != nil {
+		return apperrors.Validation(err)
+	}
+	portionPrice, err := helpers.PortionPrice(food, orderItem.PortionSize)
+	if err != nil {
+		return apperrors.Validation(err)
+	}
+
+	unitPrice := toFixed(portionPrice+modifiersPrice, 2)
+	orderItem.Modifiers = modifiers
+	orderItem.ModifiersPrice = modifiersPrice
+	orderItem.UnitPrice = &unitPrice
+
+	return nil
+}
+
+// bundleOrderItems turns a bundle selection into the BUNDLE item that carries
+// the bundle price and the zero priced BUNDLE_COMPONENT items the kitchen
+// prepares. Price deltas of modifiers chosen on a component are added to the
+// bundle's unit price.
+func (h *Handler) bundleOrderItems(ctx context.Context, orderId string, selection models.BundleSelection) ([]models.OrderItem, error) {
+	bundle := models.Bundle{}
+	if err := h.Bundles.FindByID(ctx, selection.BundleId, &bundle); err != nil {
+		return nil, apperrors.BadRequest("bundle not found")
+	}
+
+	choices, err := helpers.ResolveBundleChoices(bundle, selection.Choices)
+	if err != nil {
+		return nil, apperrors.Validation(err)
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	parent := models.OrderItem{
+		ID:         primitive.NewObjectID(),
+		ItemType:   models.OrderItemTypeBundle,
+		BundleId:   &bundle.BundleId,
+		Quantity:   selection.Quantity,
+		Course:     selection.Course,
+		SeatNumber: selection.SeatNumber,
+		OrderId:    orderId,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	parent.OrderItemId = parent.ID.Hex()
+
+	components := []models.OrderItem{}
+	modifiersPrice := 0.0
+	zero := 0.0
+
+	for _, choice := range choices {
+		food := models.Food{}
+		if err := h.Foods.FindByID(ctx, choice.FoodId, &food); err != nil || food.DeletedAt != nil {
+			return nil, apperrors.BadRequest("food not found")
+		}
+		modifiers, price, err := helpers.ResolveModifiers(food, choice.Modifiers)
+		if err != nil {
+			return nil, apperrors.Validation(err)
+		}
+		modifiersPrice += price
+
+		component := models.OrderItem{
+			ID:                  primitive.NewObjectID(),
+			ItemType:            models.OrderItemTypeBundleComponent,
+			BundleId:            &bundle.BundleId,
+			BundleSlotId:        choice.SlotId,
+			ParentOrderItemId:   &parent.OrderItemId,
+			FoodId:              &food.FoodId,
+			Quantity:            selection.Quantity,
+			Course:              selection.Course,
+			SeatNumber:          selection.SeatNumber,
+			UnitPrice:           &zero,
+			Modifiers:           modifiers,
+			ModifiersPrice:      price,
+			SpecialInstructions: choice.SpecialInstructions,
+			OrderId:             orderId,
+			CreatedAt:           now,
+			UpdatedAt:           now,
+		}
+		component.OrderItemId = component.ID.Hex()
+		components = append(components, component)
+	}
+
+	unitPrice := toFixed(*bundle.Price+modifiersPrice, 2)
+	parent.UnitPrice = &unitPrice
+	parent.ModifiersPrice = helpers.RoundPrice(modifiersPrice)
+
+	return append([]models.OrderItem{parent}, components...), nil
+}
+
+// seatFilter matches items ordered for any of the seats, where seat 0 matches
+// the items without a seat.
+func seatFilter(seats []int) bson.A {
+	filter := bson.A{bson.D{{Key: "seat_number", Value: bson.D{{Key: "$in", Value: seats}}}}}
+	for _, seat := range seats {
+		if seat == 0 {
+			filter = append(filter, bson.D{{Key: "seat_number", Value: nil}})
+			break
+		}
+	}
+	return filter
+}
+
+// approvedItems leaves out guest items a waiter hasn't approved yet or
+// rejected, those are neither cooked nor billed.
+var approvedItems = bson.E{Key: "approval_status", Value: bson.D{{Key: "$nin", Value: bson.A{
+	models.ApprovalPending,
+	models.ApprovalRejected,
+}}}}
+
+// checkSeats makes sure every seat number fits at the table the order is
+// served at.
+func (h *Handler) checkSeats(ctx context.Context, tableId *string, seats []int) error {
+	if len(seats) == 0 {
+		return nil
+	}
+	if tableId == nil {
+		return apperrors.BadRequest("seat numbers need a table")
+	}
+
+	table := models.Table{}
+	if err := h.Tables.FindByID(ctx, *tableId, &table); err != nil || table.DeletedAt != nil {
+		return apperrors.BadRequest("table not found")
+	}
+	for _, seat := range seats {
+		if table.NumberOfGuests != nil && seat > *table.NumberOfGuests {
+			return apperrors.BadRequest(fmt.Sprintf("table %d only has %d seats", *table.TableNumber, *table.NumberOfGuests))
+		}
+	}
+
+	return nil
 }
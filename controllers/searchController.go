@@ -0,0 +1,375 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// priceBoundaries split foods into the price range facets, each named by
+// its lowest price. Prices from the last boundary up fall into $bucket's
+// default bucket, which is named after that boundary too.
+var priceBoundaries = bson.A{0, 5, 10, 20, 50}
+
+// suggestionCandidates is how many names of foods, and of menus, are looked
+// at for suggestions at most.
+const suggestionCandidates = 500
+
+type Suggestion struct {
+	Type     string `json:"type"`
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Typos    int    `json:"typos"`
+	position int
+}
+
+// Search finds foods and menus by the words in `q`, ranked by relevance.
+// Foods can be narrowed down by menu `category`, `min_price` and
+// `max_price`, `tags`, `exclude_allergens` and `available` (on a menu that
+// is running right now), and come with facet counts for each of those.
+// `type` limits the search to foods or menus. Both are paged like the list
+// endpoints, a cursor pages through one type only.
+func (h *Handler) Search(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	chain, ok := languageChain(c)
+	if !ok {
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	searchType := c.DefaultQuery("type", "all")
+	if searchType != "all" && searchType != "food" && searchType != "menu" {
+		c.Error(apperrors.BadRequest("type must be all, food or menu"))
+		return
+	}
+
+	page, ok := listQuery(c, searchListFields, "-score,name")
+	if !ok {
+		return
+	}
+	if c.Query("cursor") != "" && searchType == "all" {
+		c.Error(apperrors.BadRequest("a cursor pages through one type, set type to food or menu"))
+		return
+	}
+
+	data := gin.H{}
+
+	if searchType != "menu" {
+		foodMatch, err := foodFilter(c)
+		if err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		foodMatch = append(foodMatch, notDeleted)
+		if query != "" {
+			foodMatch = append(bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}}}, foodMatch...)
+		}
+		price := bson.D{}
+		for _, bound := range []struct{ param, operator string }{{"min_price", "$gte"}, {"max_price", "$lte"}} {
+			if c.Query(bound.param) == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(c.Query(bound.param), 64)
+			if err != nil || value < 0 {
+				c.Error(apperrors.BadRequest(bound.param + " must be a positive number"))
+				return
+			}
+			price = append(price, bson.E{Key: bound.operator, Value: value})
+		}
+		if len(price) > 0 {
+			foodMatch = append(foodMatch, bson.E{Key: "price", Value: price})
+		}
+
+		menuMatch := bson.D{}
+		if categories := splitQuery(c.Query("category")); len(categories) > 0 {
+			menuMatch = append(menuMatch, bson.E{Key: "category", Value: bson.D{{Key: "$in", Value: categories}}})
+		}
+		if c.Query("available") != "" {
+			available, err := strconv.ParseBool(c.Query("available"))
+			if err != nil {
+				c.Error(apperrors.BadRequest("available must be true or false"))
+				return
+			}
+			menuMatch = append(menuMatch, bson.E{Key: "available", Value: available})
+		}
+
+		foodPage := page
+		foodPage.Filter = append(foodMatch, page.Filter...)
+		foods, err := h.searchFoods(ctx, query != "", foodPage, menuMatch, chain)
+		if err != nil {
+			searchFailed(c, err, "cannot search the foods")
+			return
+		}
+		data["foods"] = gin.H{"results": foods.documents, "facets": foods.facets, "pagination": foods.pagination(foodPage)}
+	}
+
+	if searchType != "food" {
+		menuMatch := bson.D{}
+		if query != "" {
+			menuMatch = append(menuMatch, bson.E{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}})
+		}
+		if categories := splitQuery(c.Query("category")); len(categories) > 0 {
+			menuMatch = append(menuMatch, bson.E{Key: "category", Value: bson.D{{Key: "$in", Value: categories}}})
+		}
+		menuMatch = append(menuMatch, notDeleted)
+
+		menuPage := page
+		menuPage.Filter = append(menuMatch, page.Filter...)
+		menus, err := h.searchMenus(ctx, query != "", menuPage, chain)
+		if err != nil {
+			searchFailed(c, err, "cannot search the menus")
+			return
+		}
+		data["menus"] = gin.H{"results": menus.documents, "pagination": menus.pagination(menuPage)}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": data})
+}
+
+// SearchSuggestions completes what the waiter is typing in `q` to food and
+// menu names. Every word of a name is matched by prefix, tolerating a typo
+// in queries of four letters or more and two in longer ones, accents and
+// case ignored. Exact prefixes rank first. Only names with a word starting
+// with one of the first letters of `q` are looked at.
+func (h *Handler) SearchSuggestions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	query := helpers.FoldText(strings.TrimSpace(c.Query("q")))
+	if query == "" {
+		c.JSON(200, gin.H{"status": "success", "data": []Suggestion{}})
+		return
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 || limit > 20 {
+		limit = 8
+	}
+	maxTypos := helpers.MaxTypos(len([]rune(query)))
+
+	type named struct {
+		Name   string `bson:"name"`
+		FoodId string `bson:"food_id"`
+		MenuId string `bson:"menu_id"`
+	}
+	suggestions := []Suggestion{}
+	suggest := func(kind string, id string, name string) {
+		best, position := maxTypos+1, 0
+		for i, word := range strings.Fields(helpers.FoldText(name)) {
+			if typos := helpers.PrefixDistance(query, word); typos < best {
+				best, position = typos, i
+			}
+		}
+		// the whole name is tried too, for queries spanning several words
+		if typos := helpers.PrefixDistance(query, helpers.FoldText(name)); typos <= best {
+			best, position = typos, 0
+		}
+		if best <= maxTypos {
+			suggestions = append(suggestions, Suggestion{Type: kind, Id: id, Name: name, Typos: best, position: position})
+		}
+	}
+
+	candidates := bson.D{notDeleted, {Key: "name", Value: bson.D{
+		{Key: "$regex", Value: helpers.WordStartPattern(query, maxTypos)},
+		{Key: "$options", Value: "i"},
+	}}}
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "name", Value: 1}, {Key: "food_id", Value: 1}, {Key: "menu_id", Value: 1}}).
+		SetLimit(suggestionCandidates)
+	for _, source := range []struct {
+		kind       string
+		collection repositories.Collection
+	}{{"food", h.Foods}, {"menu", h.Menus}} {
+		cursor, err := source.collection.Find(ctx, candidates, opts)
+		if err != nil {
+			c.Error(apperrors.Internal(fmt.Errorf("cannot get suggestions: %w", err)))
+			return
+		}
+		documents := []named{}
+		if err := cursor.All(ctx, &documents); err != nil {
+			c.Error(apperrors.Internal(fmt.Errorf("cannot get suggestions: %w", err)))
+			return
+		}
+		for _, document := range documents {
+			id := document.FoodId
+			if source.kind == "menu" {
+				id = document.MenuId
+			}
+			suggest(source.kind, id, document.Name)
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Typos != suggestions[j].Typos {
+			return suggestions[i].Typos < suggestions[j].Typos
+		}
+		if suggestions[i].position != suggestions[j].position {
+			return suggestions[i].position < suggestions[j].position
+		}
+		return suggestions[i].Name < suggestions[j].Name
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": suggestions})
+}
+
+func (h *Handler) searchFoods(ctx context.Context, ranked bool, query helpers.ListQuery, menuMatch bson.D, chain []string) (listPage, error) {
+	score := interface{}(0)
+	if ranked {
+		score = bson.D{{Key: "$meta", Value: "textScore"}}
+	}
+
+	stages := []bson.D{
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "menu"},
+			{Key: "localField", Value: "menu_id"},
+			{Key: "foreignField", Value: "menu_id"},
+			{Key: "as", Value: "menu"},
+		}}},
+		{{Key: "$unwind", Value: bson.D{
+			{Key: "path", Value: "$menu"},
+			{Key: "preserveNullAndEmptyArrays", Value: true},
+		}}},
+		{{Key: "$addFields", Value: bson.D{
+			{Key: "score", Value: score},
+			{Key: "category", Value: "$menu.category"},
+			{Key: "available", Value: menuAvailable("$menu", time.Now())},
+		}}},
+	}
+	if len(menuMatch) > 0 {
+		stages = append(stages, bson.D{{Key: "$match", Value: menuMatch}})
+	}
+	stages = append(stages, helpers.LocalizeStage(chain))
+
+	query.PageStages = []bson.D{
+		{{Key: "$project", Value: bson.D{
+			{Key: "food_id", Value: 1},
+			{Key: "name", Value: 1},
+			{Key: "description", Value: 1},
+			{Key: "language", Value: 1},
+			{Key: "price", Value: 1},
+			{Key: "image_id", Value: 1},
+			{Key: "allergens", Value: 1},
+			{Key: "dietary_tags", Value: 1},
+			{Key: "menu_id", Value: 1},
+			{Key: "menu_name", Value: "$menu.name"},
+			{Key: "category", Value: 1},
+			{Key: "available", Value: 1},
+			{Key: "score", Value: 1},
+		}}},
+	}
+	query.Facets = bson.D{
+		{Key: "categories", Value: facetCounts("$category")},
+		{Key: "dietary_tags", Value: append(bson.A{bson.D{{Key: "$unwind", Value: "$dietary_tags"}}}, facetCounts("$dietary_tags")...)},
+		{Key: "availability", Value: facetCounts("$available")},
+		{Key: "price_ranges", Value: bson.A{
+			bson.D{{Key: "$bucket", Value: bson.D{
+				{Key: "groupBy", Value: "$price"},
+				{Key: "boundaries", Value: priceBoundaries},
+				{Key: "default", Value: priceBoundaries[len(priceBoundaries)-1]},
+				{Key: "output", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}},
+			}}},
+			bson.D{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "min_price", Value: "$_id"},
+				{Key: "count", Value: 1},
+			}}},
+		}},
+	}
+
+	return findPage(ctx, h.Foods, query, stages...)
+}
+
+func (h *Handler) searchMenus(ctx context.Context, ranked bool, query helpers.ListQuery, chain []string) (listPage, error) {
+	score := interface{}(0)
+	if ranked {
+		score = bson.D{{Key: "$meta", Value: "textScore"}}
+	}
+
+	query.PageStages = []bson.D{
+		{{Key: "$project", Value: bson.D{
+			{Key: "menu_id", Value: 1},
+			{Key: "name", Value: 1},
+			{Key: "description", Value: 1},
+			{Key: "language", Value: 1},
+			{Key: "category", Value: 1},
+			{Key: "available", Value: 1},
+			{Key: "score", Value: 1},
+		}}},
+	}
+
+	return findPage(ctx, h.Menus, query,
+		bson.D{{Key: "$addFields", Value: bson.D{
+			{Key: "score", Value: score},
+			{Key: "available", Value: menuAvailable("$$ROOT", time.Now())},
+		}}},
+		helpers.LocalizeStage(chain),
+	)
+}
+
+// menuAvailable is the expression telling whether the menu at path is
+// running at now, like activeMenuFilter does for queries.
+func menuAvailable(path string, now time.Time) bson.D {
+	return bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{path + ".start_date", nil}}}, nil}}},
+			bson.D{{Key: "$lte", Value: bson.A{path + ".start_date", now}}},
+		}}},
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{path + ".end_date", nil}}}, nil}}},
+			bson.D{{Key: "$gte", Value: bson.A{path + ".end_date", now}}},
+		}}},
+		bson.D{{Key: "$ne", Value: bson.A{bson.D{{Key: "$type", Value: path}}, "missing"}}},
+	}}}
+}
+
+func facetCounts(field string) bson.A {
+	return bson.A{
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: field},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "value", Value: "$_id"},
+			{Key: "count", Value: 1},
+		}}},
+	}
+}
+
+func splitQuery(query string) []string {
+	values := []string{}
+	for _, value := range strings.Split(query, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// searchFailed tells apart a database that has not been migrated yet, $text
+// queries need the text indexes the migrations create.
+func searchFailed(c *gin.Context, err error, message string) {
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == 27 {
+		c.Error(apperrors.Unavailable("search indexes are missing, run the migrations"))
+		return
+	}
+	c.Error(apperrors.Internal(fmt.Errorf("%s: %w", message, err)))
+}
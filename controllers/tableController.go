@@ -2,69 +2,75 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"time"
 
-	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
 	"github.com/RahulMj21/mongo-restaurant-management/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var tableCollection = database.OpenCollection(database.Client, "table")
-
-func GetTables(c *gin.Context) {
+func (h *Handler) GetTables(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	cursor, err := tableCollection.Find(ctx, bson.M{})
-	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "cannot get tables"})
+	query, ok := listQuery(c, tableListFields, "table_number")
+	if !ok {
 		return
 	}
-	tables := []bson.M{}
+	query.Filter = withoutDeleted(c, query.Filter)
 
-	if err := cursor.All(ctx, &tables); err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "unable to listing tables"})
-		return
-	}
-
-	c.JSON(200, gin.H{"status": "success", "data": tables})
+	list(ctx, c, h.Tables, query)
 }
 
-func GetTable(c *gin.Context) {
+func (h *Handler) GetTable(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	tableId := c.Param("id")
 	if tableId == "" {
-		c.JSON(400, gin.H{"status": "fail", "message": "id cannot be empty"})
+		c.Error(apperrors.BadRequest("id cannot be empty"))
 		return
 	}
-	table := models.OrderItem{}
-	err := tableCollection.FindOne(ctx, bson.D{{Key: "table_id", Value: tableId}}).Decode(&table)
+	table := models.Table{}
+	err := h.Tables.FindByID(ctx, tableId, &table)
+	if err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("table not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the table: %w", err)))
+		return
+	}
+
+	notes, err := h.notesFor(ctx, models.NoteEntityTable, table.TableId)
 	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "cannot get the table"})
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the table notes: %w", err)))
 		return
 	}
+	table.Notes = notes
 
+	setETag(c, table.Version)
 	c.JSON(200, gin.H{"status": "success", "data": table})
 }
 
-func CreateTable(c *gin.Context) {
+func (h *Handler) CreateTable(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	table := models.Table{}
-	if err := c.BindJSON(&table); err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": err.Error()})
+	if err := c.ShouldBindJSON(&table); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	validationErr := validate.Struct(table)
 	if validationErr != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": validationErr.Error()})
+		c.Error(apperrors.Validation(validationErr))
 		return
 	}
 
@@ -73,58 +79,162 @@ func CreateTable(c *gin.Context) {
 	table.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	table.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
-	insertedItem, err := tableCollection.InsertOne(ctx, table)
+	insertedItem, err := h.Tables.InsertOne(ctx, table)
 	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "failed to create table"})
+		c.Error(apperrors.Internal(fmt.Errorf("failed to create table: %w", err)))
 		return
 	}
 
 	newTable := models.Table{}
-	if err := tableCollection.FindOne(ctx, bson.D{{Key: "_id", Value: insertedItem.InsertedID}}).Decode(&newTable); err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+	if err := h.Tables.FindOne(ctx, bson.D{{Key: "_id", Value: insertedItem.InsertedID}}).Decode(&newTable); err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
 	c.JSON(201, gin.H{"status": "success", "data": newTable})
 }
 
-func UpdateTable(c *gin.Context) {
+// UpdateTable replaces the table on PUT and merge patches it on PATCH.
+func (h *Handler) UpdateTable(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	tableId := c.Param("id")
 	if tableId == "" {
-		c.JSON(400, gin.H{"status": "fail", "message": "id cannot be empty"})
+		c.Error(apperrors.BadRequest("id cannot be empty"))
 		return
 	}
-	table := models.Table{}
-	if err := c.BindJSON(&table); err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": err.Error()})
+	current := models.Table{}
+	if err := h.Tables.FindByID(ctx, tableId, &current); err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("table not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	tableObj := primitive.D{}
+	if !checkIfMatch(c, current.Version) {
+		return
+	}
 
-	if table.NumberOfGuests != nil {
-		tableObj = append(tableObj, bson.E{Key: "number_of_guests", Value: table.NumberOfGuests})
+	table := models.Table{}
+	if err := readUpdate(c, current, &table); err != nil {
+		updateBodyFailed(c, err)
+		return
 	}
-	if table.TableNumber != nil {
-		tableObj = append(tableObj, bson.E{Key: "table_number", Value: table.TableNumber})
+	table.ID, table.TableId, table.CreatedAt = current.ID, current.TableId, current.CreatedAt
+	table.DeletedAt, table.DeletedBy = current.DeletedAt, current.DeletedBy
+	table.LinkNonce = current.LinkNonce
+	table.Notes = nil
+
+	if err := validate.Struct(table); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
 	}
+
+	table.Version = current.Version + 1
 	table.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	tableObj = append(tableObj, bson.E{Key: "updated_at", Value: table.UpdatedAt})
 
-	filter := bson.D{{Key: "table_id", Value: tableId}}
-	upsert := true
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+	if err := replaceDocument(ctx, h.Tables, table.ID, current.Version, table); err != nil {
+		updateFailed(c, err, "table not found")
+		return
+	}
+	setETag(c, table.Version)
+
+	c.JSON(200, gin.H{"status": "success", "data": table})
+}
+
+// GetTableQRCode returns the signed guest ordering link of the table. With
+// `format=png` (and an optional pixel `size`) or `format=svg` it renders the
+// link as a QR code for printing the table card.
+func (h *Handler) GetTableQRCode(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	table := models.Table{}
+	if err := h.Tables.FindByID(ctx, c.Param("id"), &table); err == mongo.ErrNoDocuments || (err == nil && table.DeletedAt != nil) {
+		c.Error(apperrors.NotFound("table not found"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
 	}
 
-	result, err := tableCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: tableObj}}, &opt)
+	token, err := helpers.SignTableLink(table.TableId, table.LinkNonce)
 	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
+		c.Error(apperrors.Internal(err))
 		return
 	}
+	url := helpers.TableLinkURL(token)
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(200, gin.H{"status": "success", "data": gin.H{
+			"table_id":     table.TableId,
+			"table_number": table.TableNumber,
+			"token":        token,
+			"url":          url,
+		}})
+	case "png":
+		size, err := strconv.Atoi(c.DefaultQuery("size", "256"))
+		if err != nil || size < 64 || size > 2048 {
+			c.Error(apperrors.BadRequest("size must be between 64 and 2048"))
+			return
+		}
+		png, err := helpers.QRCodePNG(url, size)
+		if err != nil {
+			c.Error(apperrors.Internal(err))
+			return
+		}
+		c.Data(200, "image/png", png)
+	case "svg":
+		svg, err := helpers.QRCodeSVG(url)
+		if err != nil {
+			c.Error(apperrors.Internal(err))
+			return
+		}
+		c.Data(200, "image/svg+xml", svg)
+	default:
+		c.Error(apperrors.BadRequest("format must be json, png or svg"))
+	}
+}
+
+// RotateTableLink gives the table a new guest ordering link. The code printed
+// with the old one stops working, so a card that walked off or was copied can
+// be retired by printing the new one.
+func (h *Handler) RotateTableLink(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
 
-	c.JSON(200, gin.H{"status": "success", "data": result})
+	tableId := c.Param("id")
+	nonce, err := helpers.NewTableLinkNonce()
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	result, err := h.Tables.UpdateOne(ctx, bson.D{{Key: "table_id", Value: tableId}, notDeleted}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "link_nonce", Value: nonce}, {Key: "updated_at", Value: updatedAt}}},
+		bumpVersion,
+	})
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.Error(apperrors.NotFound("table not found"))
+		return
+	}
+
+	token, err := helpers.SignTableLink(tableId, nonce)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+	c.JSON(200, gin.H{"status": "success", "data": gin.H{
+		"table_id": tableId,
+		"token":    token,
+		"url":      helpers.TableLinkURL(token),
+	}})
 }
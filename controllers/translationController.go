@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MissingTranslation struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	MenuId  *string  `json:"menu_id,omitempty"`
+	Missing []string `json:"missing"`
+}
+
+func (h *Handler) PutMenuTranslation(c *gin.Context) {
+	putTranslation(c, h.Menus, "menu_id")
+}
+
+func (h *Handler) DeleteMenuTranslation(c *gin.Context) {
+	deleteTranslation(c, h.Menus, "menu_id")
+}
+
+func (h *Handler) PutFoodTranslation(c *gin.Context) {
+	putTranslation(c, h.Foods, "food_id")
+}
+
+func (h *Handler) DeleteFoodTranslation(c *gin.Context) {
+	deleteTranslation(c, h.Foods, "food_id")
+}
+
+// GetMissingTranslations shows the menus and foods that still lack a
+// translated name or description. It checks the `lang` query parameter, or
+// else the supported languages plus every language something has already
+// been translated into.
+func (h *Handler) GetMissingTranslations(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	menus := []models.Menu{}
+	cursor, err := h.Menus.Find(ctx, bson.D{notDeleted})
+	if err == nil {
+		err = cursor.All(ctx, &menus)
+	}
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the menus: %w", err)))
+		return
+	}
+
+	foods := []models.Food{}
+	cursor, err = h.Foods.Find(ctx, bson.D{notDeleted})
+	if err == nil {
+		err = cursor.All(ctx, &foods)
+	}
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("cannot get the foods: %w", err)))
+		return
+	}
+
+	languages := helpers.SupportedLanguages()
+	if c.Query("lang") != "" {
+		language, err := helpers.NormalizeLanguage(c.Query("lang"))
+		if err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
+		languages = []string{language}
+	} else {
+		inUse := map[string]bool{}
+		for _, language := range languages {
+			inUse[language] = true
+		}
+		addInUse := func(translations map[string]models.Translation) {
+			for language := range translations {
+				if !inUse[language] {
+					inUse[language] = true
+					languages = append(languages, language)
+				}
+			}
+		}
+		for _, menu := range menus {
+			addInUse(menu.Translations)
+		}
+		for _, food := range foods {
+			addInUse(food.Translations)
+		}
+	}
+
+	missingCounts := map[string]int{}
+	for _, language := range languages {
+		missingCounts[language] = 0
+	}
+
+	missingMenus := []MissingTranslation{}
+	for _, menu := range menus {
+		missing := helpers.MissingTranslations(languages, menu.Description, menu.Translations)
+		if len(missing) > 0 {
+			missingMenus = append(missingMenus, MissingTranslation{Id: menu.MenuId, Name: menu.Name, Missing: missing})
+		}
+		for _, language := range missing {
+			missingCounts[language]++
+		}
+	}
+
+	missingFoods := []MissingTranslation{}
+	for _, food := range foods {
+		name := ""
+		if food.Name != nil {
+			name = *food.Name
+		}
+		missing := helpers.MissingTranslations(languages, food.Description, food.Translations)
+		if len(missing) > 0 {
+			missingFoods = append(missingFoods, MissingTranslation{Id: food.FoodId, Name: name, MenuId: food.MenuId, Missing: missing})
+		}
+		for _, language := range missing {
+			missingCounts[language]++
+		}
+	}
+
+	c.JSON(200, gin.H{"status": "success", "data": gin.H{
+		"default_language": helpers.DefaultLanguage(),
+		"languages":        languages,
+		"missing_counts":   missingCounts,
+		"menus":            missingMenus,
+		"foods":            missingFoods,
+	}})
+}
+
+// putTranslation sets the translation of one language, leaving the others
+// as they are. Like every update of the menu or food it needs If-Match.
+func putTranslation(c *gin.Context, collection repositories.Collection, idKey string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	translation := models.Translation{}
+	if err := c.ShouldBindJSON(&translation); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if err := validate.Struct(translation); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	language, err := helpers.NormalizeLanguage(c.Param("lang"))
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	translations, err := helpers.PrepareTranslations(map[string]models.Translation{language: translation})
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	translation = translations[language]
+	if translation.Name == nil && translation.Description == nil {
+		c.Error(apperrors.BadRequest("translation needs a name or a description"))
+		return
+	}
+
+	current, ok := translatedVersion(ctx, c, collection, idKey)
+	if !ok {
+		return
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "translations." + language, Value: translation},
+		{Key: "updated_at", Value: updatedAt},
+	}}, bumpVersion}
+
+	updateTranslations(ctx, c, collection, current, update)
+}
+
+func deleteTranslation(c *gin.Context, collection repositories.Collection, idKey string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	language, err := helpers.NormalizeLanguage(c.Param("lang"))
+	if err != nil || language == "" {
+		c.Error(apperrors.BadRequest("invalid language"))
+		return
+	}
+
+	current, ok := translatedVersion(ctx, c, collection, idKey)
+	if !ok {
+		return
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.D{
+		{Key: "$unset", Value: bson.D{{Key: "translations." + language, Value: ""}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: updatedAt}}},
+		bumpVersion,
+	}
+
+	updateTranslations(ctx, c, collection, current, update)
+}
+
+// versioned is the part of a menu or food a translation update needs.
+type versioned struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Version int64              `bson:"version"`
+}
+
+// translatedVersion reads the version of the menu or food whose translations
+// the request changes and checks it against If-Match. It fails the request
+// itself and tells whether the handler may go on.
+func translatedVersion(ctx context.Context, c *gin.Context, collection repositories.Collection, idKey string) (versioned, bool) {
+	current := versioned{}
+	err := collection.FindOne(ctx, bson.D{{Key: idKey, Value: c.Param("id")}}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("not found"))
+		return current, false
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
+		return current, false
+	}
+	return current, checkIfMatch(c, current.Version)
+}
+
+// updateTranslations applies update to current, provided nobody changed it
+// since its version was read.
+func updateTranslations(ctx context.Context, c *gin.Context, collection repositories.Collection, current versioned, update bson.D) {
+	result, err := collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: current.ID}, versionFilter(current.Version)}, update)
+	if err == nil && result.MatchedCount == 0 {
+		err = missingOrChanged(ctx, collection, current.ID)
+	}
+	if err != nil {
+		updateFailed(c, err, "not found")
+		return
+	}
+
+	setETag(c, current.Version+1)
+	c.JSON(200, gin.H{"status": "success", "data": result})
+}
+
+// languageChain reads the languages the client accepts from the `lang` query
+// parameter and the Accept-Language header. It fails the request itself and
+// tells whether the handler may go on.
+func languageChain(c *gin.Context) ([]string, bool) {
+	c.Header("Vary", "Accept-Language")
+
+	chain, err := helpers.LanguageChain(c.Query("lang"), c.GetHeader("Accept-Language"))
+	if err != nil {
+		c.Error(apperrors.Validation(err))
+		return nil, false
+	}
+	return chain, true
+}
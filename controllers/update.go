@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/RahulMj21/mongo-restaurant-management/helpers"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const mergePatchType = "application/merge-patch+json"
+
+var errPatchType = errors.New("PATCH takes a JSON Merge Patch, send it as " + mergePatchType)
+
+// readUpdate fills next with the resource as the request wants it stored.
+// A PUT body is the whole resource. A PATCH body is a JSON Merge Patch
+// (RFC 7396) on current, members left out keep their value and members set
+// to null are cleared. Plain application/json patches are read the same way.
+func readUpdate(c *gin.Context, current, next interface{}) error {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+
+	if c.Request.Method == "PATCH" {
+		switch c.ContentType() {
+		case mergePatchType, gin.MIMEJSON, "":
+		default:
+			return errPatchType
+		}
+		document, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		if body, err = helpers.MergePatch(document, body); err != nil {
+			return err
+		}
+	}
+
+	return json.Unmarshal(body, next)
+}
+
+// updateBodyFailed answers a body readUpdate could not read.
+func updateBodyFailed(c *gin.Context, err error) {
+	if errors.Is(err, errPatchType) {
+		c.Error(apperrors.UnsupportedMediaType(err.Error()))
+		return
+	}
+	c.Error(apperrors.Validation(err))
+}
+
+var errVersionMismatch = errors.New("the resource changed since it was read, fetch it again")
+
+// setETag tags the response with the version of the document it carries.
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// checkIfMatch lets an update through only when its If-Match names the
+// version it is about to change, so nobody overwrites an edit they never
+// saw. It answers 428 without the header and 412 on a mismatch.
+func checkIfMatch(c *gin.Context, version int64) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.Error(apperrors.PreconditionRequired("send the ETag you last read in If-Match"))
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	want := `"` + strconv.FormatInt(version, 10) + `"`
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == want {
+			return true
+		}
+	}
+	c.Error(apperrors.PreconditionFailed(errVersionMismatch.Error()))
+	return false
+}
+
+// versionFilter matches a document still at version. Documents stored
+// before versions existed have none and count as version 0.
+func versionFilter(version int64) bson.E {
+	if version == 0 {
+		return bson.E{Key: "version", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}}
+	}
+	return bson.E{Key: "version", Value: version}
+}
+
+// bumpVersion is the part of an update that moves the document to its next
+// version. Every write to a versioned document carries it.
+var bumpVersion = bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: int64(1)}}}
+
+// replaceDocument stores document in place of the one with the same _id,
+// provided that one is still at version. It returns mongo.ErrNoDocuments
+// when it is gone and errVersionMismatch when it moved on.
+func replaceDocument(ctx context.Context, collection repositories.Collection, id primitive.ObjectID, version int64, document interface{}) error {
+	result, err := collection.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}, versionFilter(version)}, document)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return missingOrChanged(ctx, collection, id)
+	}
+	return nil
+}
+
+// missingOrChanged tells why a versioned write matched nothing.
+func missingOrChanged(ctx context.Context, collection repositories.Collection, id primitive.ObjectID) error {
+	count, err := collection.CountDocuments(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return errVersionMismatch
+}
+
+// updateFailed answers a failed versioned write.
+func updateFailed(c *gin.Context, err error, notFound string) {
+	switch {
+	case err == mongo.ErrNoDocuments:
+		c.Error(apperrors.NotFound(notFound))
+	case errors.Is(err, errVersionMismatch):
+		c.Error(apperrors.PreconditionFailed(err.Error()))
+	default:
+		c.Error(apperrors.Internal(err))
+	}
+}
@@ -1,11 +1,11 @@
 package controllers
 
 import (
+	"fmt"
 	"log"
-	"strconv"
 	"time"
 
-	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
 	"github.com/RahulMj21/mongo-restaurant-management/helpers"
 	"github.com/RahulMj21/mongo-restaurant-management/models"
 	"github.com/gin-gonic/gin"
@@ -17,72 +17,30 @@ import (
 	"golang.org/x/net/context"
 )
 
-var userCollection = database.OpenCollection(database.Client, "user")
-
-func GetUsers(c *gin.Context) {
+func (h *Handler) GetUsers(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	recordsPerPage, recordsErr := strconv.Atoi(c.Query("recordsPerPage"))
-	if recordsErr != nil || recordsPerPage <= 0 {
-		recordsPerPage = 10
-	}
-
-	page, pageErr := strconv.Atoi(c.Query("page"))
-	if pageErr != nil || page <= 0 {
-		page = 1
+	query, ok := listQuery(c, userListFields, "-created_at")
+	if !ok {
+		return
 	}
 
-	startIndex := (page - 1) * recordsPerPage
-
-	matchStage := bson.D{{Key: "$match", Value: bson.D{{}}}}
 	projectStage := bson.D{{Key: "$project", Value: bson.D{
-		{Key: "_id", Value: 1},
-		{Key: "first_name", Value: 1},
-		{Key: "last_name", Value: 1},
 		{Key: "password", Value: 0},
-		{Key: "email", Value: 1},
-		{Key: "avatar", Value: 1},
-		{Key: "phone", Value: 1},
 		{Key: "access_token", Value: 0},
 		{Key: "refresh_token", Value: 0},
-		{Key: "created_at", Value: 1},
-		{Key: "updated_at", Value: 1},
-		{Key: "user_id", Value: 1},
-	}}}
-	groupStage := bson.D{{Key: "$group", Value: bson.D{
-		{Key: "_id", Value: bson.D{{Key: "_id", Value: "null"}}},
-		{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
-		{Key: "data", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
-	}}}
-	projectStage2 := bson.D{{Key: "$project", Value: bson.D{
-		{Key: "_id", Value: 0},
-		{Key: "total_count", Value: 1},
-		{Key: "users", Value: bson.D{{Key: "$slice", Value: []interface{}{"$data", startIndex, recordsPerPage}}}},
 	}}}
-
-	cursor, err := userCollection.Aggregate(ctx, mongo.Pipeline{matchStage, projectStage, groupStage, projectStage2})
-	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "cannot get users"})
-		return
-	}
-
-	users := []primitive.M{}
-	if err := cursor.All(ctx, &users); err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "error while listing users"})
-		return
-	}
-
-	c.JSON(200, gin.H{"status": "success", "data": users})
+	list(ctx, c, h.Users, query, projectStage)
 }
 
-func GetUser(c *gin.Context) {
+func (h *Handler) GetUser(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	userId := c.Param("id")
 	if userId == "" {
-		c.JSON(400, gin.H{"status": "fail", "message": "user_id cannot be empty"})
+		c.Error(apperrors.BadRequest("user_id cannot be empty"))
 		return
 	}
 
@@ -93,38 +51,29 @@ func GetUser(c *gin.Context) {
 		{Key: "refresh_token", Value: 0},
 	})
 
-	err := userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: userId}}, opt).Decode(&user)
+	err := h.Users.FindOne(ctx, bson.D{{Key: "user_id", Value: userId}}, opt).Decode(&user)
 	if err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": "user not found"})
+		c.Error(apperrors.NotFound("user not found"))
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "success"})
+	setETag(c, user.Version)
+	c.JSON(200, gin.H{"status": "success", "data": user})
 }
 
-func SignUp(c *gin.Context) {
+func (h *Handler) SignUp(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	user := models.User{}
-	if err := c.BindJSON(&user); err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": err.Error()})
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	validationErr := validate.Struct(user)
 	if validationErr != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": validationErr.Error()})
-		return
-	}
-
-	count, err := userCollection.CountDocuments(ctx, bson.D{{Key: "email", Value: user.Email}})
-	if err != nil {
-		c.JSON(500, gin.H{"status": "fail", "message": err.Error()})
-		return
-	}
-	if count > 0 {
-		c.JSON(500, gin.H{"status": "fail", "message": "email already taken"})
+		c.Error(apperrors.Validation(validationErr))
 		return
 	}
 
@@ -140,9 +89,14 @@ func SignUp(c *gin.Context) {
 	user.AccessToken = &accessToken
 	user.RefreshToken = &refreshToken
 
-	insertedItem, err := userCollection.InsertOne(ctx, user)
+	// the unique index on email settles concurrent sign ups
+	insertedItem, err := h.Users.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		c.Error(apperrors.Conflict("email already taken"))
+		return
+	}
 	if err != nil || insertedItem.InsertedID == nil {
-		c.JSON(500, gin.H{"status": "fail", "message": "user creation failed"})
+		c.Error(apperrors.Internal(fmt.Errorf("user creation failed: %w", err)))
 		return
 	}
 
@@ -167,34 +121,36 @@ type LoginBody struct {
 	Password string `json:"password" validate:"required"`
 }
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	body := LoginBody{}
 
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": err.Error()})
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 	validationErr := validate.Struct(body)
 	if validationErr != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": validationErr.Error()})
+		c.Error(apperrors.Validation(validationErr))
 		return
 	}
 
 	user := models.User{}
-	err := userCollection.FindOne(ctx, bson.D{{Key: "email", Value: &body.Email}}).Decode(&user)
-	if err != nil {
-		c.JSON(400, gin.H{"status": "fail", "message": "wrong email"})
+	err := h.Users.FindOne(ctx, bson.D{{Key: "email", Value: &body.Email}}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		c.Error(apperrors.BadRequest("wrong email"))
+		return
+	} else if err != nil {
+		c.Error(apperrors.Internal(err))
 		return
 	}
 
-	isVerified := VerifyPassword(*user.Password, body.Password)
-	if isVerified == false {
+	if user.Password == nil || !VerifyPassword(*user.Password, body.Password) {
+		c.Error(apperrors.BadRequest("wrong password"))
+		return
 	}
-	c.JSON(400, gin.H{"status": "fail", "message": "wrong password"})
-	return
 
 	// need to add session model for storing refreshToken
 	// we need to createTokens and store them and send to user
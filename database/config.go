@@ -0,0 +1,205 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config is how the service reaches Mongo. It starts from the defaults, is
+// overlaid by the JSON file named in MONGODB_CONFIG_FILE and then by the
+// MONGODB_* environment variables.
+type Config struct {
+	URI      string `json:"uri"`
+	Database string `json:"database"`
+
+	MinPoolSize     uint64   `json:"min_pool_size"`
+	MaxPoolSize     uint64   `json:"max_pool_size"`
+	MaxConnIdleTime Duration `json:"max_conn_idle_time"`
+
+	ConnectTimeout         Duration `json:"connect_timeout"`
+	ServerSelectionTimeout Duration `json:"server_selection_timeout"`
+	SocketTimeout          Duration `json:"socket_timeout"`
+
+	TLS  TLSConfig  `json:"tls"`
+	Auth AuthConfig `json:"auth"`
+
+	// ConnectRetries is how many more times startup tries to reach the
+	// server, waiting RetryBackoff and then twice as long each time, up to
+	// MaxRetryBackoff.
+	ConnectRetries  int      `json:"connect_retries"`
+	RetryBackoff    Duration `json:"retry_backoff"`
+	MaxRetryBackoff Duration `json:"max_retry_backoff"`
+}
+
+type TLSConfig struct {
+	Enabled bool   `json:"enabled"`
+	CAFile  string `json:"ca_file"`
+	// CertificateKeyFile holds the client certificate and its key in PEM.
+	CertificateKeyFile string `json:"certificate_key_file"`
+	Insecure           bool   `json:"insecure"`
+}
+
+// AuthConfig overrides the credentials of the URI. It is ignored when both
+// Username and Mechanism are empty.
+type AuthConfig struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	Source    string `json:"source"`
+	Mechanism string `json:"mechanism"`
+}
+
+// Duration reads "10s" style durations from the config file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("durations look like \"10s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// DefaultConfig reaches the single node replica set of docker-compose.yml.
+// The service needs a replica set or a sharded cluster, invoices are numbered
+// in transactions and a standalone mongod answers those requests with 503.
+func DefaultConfig() Config {
+	return Config{
+		URI:                    "mongodb://localhost:27017/?replicaSet=rs0",
+		Database:               "restaurant",
+		MaxPoolSize:            100,
+		ConnectTimeout:         Duration(10 * time.Second),
+		ServerSelectionTimeout: Duration(10 * time.Second),
+		ConnectRetries:         5,
+		RetryBackoff:           Duration(time.Second),
+		MaxRetryBackoff:        Duration(30 * time.Second),
+	}
+}
+
+// LoadConfig reads the configuration from MONGODB_CONFIG_FILE and the
+// environment.
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+
+	if path := os.Getenv("MONGODB_CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	env := envReader{}
+	env.string("MONGODB_URI", &config.URI)
+	env.string("MONGODB_DATABASE", &config.Database)
+	env.uint("MONGODB_MIN_POOL_SIZE", &config.MinPoolSize)
+	env.uint("MONGODB_MAX_POOL_SIZE", &config.MaxPoolSize)
+	env.duration("MONGODB_MAX_CONN_IDLE_TIME", &config.MaxConnIdleTime)
+	env.duration("MONGODB_CONNECT_TIMEOUT", &config.ConnectTimeout)
+	env.duration("MONGODB_SERVER_SELECTION_TIMEOUT", &config.ServerSelectionTimeout)
+	env.duration("MONGODB_SOCKET_TIMEOUT", &config.SocketTimeout)
+	env.bool("MONGODB_TLS", &config.TLS.Enabled)
+	env.string("MONGODB_TLS_CA_FILE", &config.TLS.CAFile)
+	env.string("MONGODB_TLS_CERTIFICATE_KEY_FILE", &config.TLS.CertificateKeyFile)
+	env.bool("MONGODB_TLS_INSECURE", &config.TLS.Insecure)
+	env.string("MONGODB_USERNAME", &config.Auth.Username)
+	env.string("MONGODB_PASSWORD", &config.Auth.Password)
+	env.string("MONGODB_AUTH_SOURCE", &config.Auth.Source)
+	env.string("MONGODB_AUTH_MECHANISM", &config.Auth.Mechanism)
+	env.int("MONGODB_CONNECT_RETRIES", &config.ConnectRetries)
+	env.duration("MONGODB_RETRY_BACKOFF", &config.RetryBackoff)
+	env.duration("MONGODB_MAX_RETRY_BACKOFF", &config.MaxRetryBackoff)
+	if env.err != nil {
+		return config, env.err
+	}
+
+	if config.Database == "" {
+		return config, fmt.Errorf("database name must not be empty")
+	}
+	if config.MinPoolSize > config.MaxPoolSize && config.MaxPoolSize != 0 {
+		return config, fmt.Errorf("min pool size %d is above the max pool size %d", config.MinPoolSize, config.MaxPoolSize)
+	}
+	if config.ConnectRetries < 0 {
+		return config, fmt.Errorf("connect retries must not be negative")
+	}
+
+	return config, nil
+}
+
+// envReader overrides config values with the environment variables that are
+// set, keeping the first malformed one as its error.
+type envReader struct {
+	err error
+}
+
+func (r *envReader) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	return value, ok && value != "" && r.err == nil
+}
+
+func (r *envReader) fail(key string, err error) {
+	r.err = fmt.Errorf("%s: %w", key, err)
+}
+
+func (r *envReader) string(key string, target *string) {
+	if value, ok := r.lookup(key); ok {
+		*target = value
+	}
+}
+
+func (r *envReader) uint(key string, target *uint64) {
+	if value, ok := r.lookup(key); ok {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			r.fail(key, err)
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (r *envReader) int(key string, target *int) {
+	if value, ok := r.lookup(key); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			r.fail(key, err)
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (r *envReader) bool(key string, target *bool) {
+	if value, ok := r.lookup(key); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			r.fail(key, err)
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (r *envReader) duration(key string, target *Duration) {
+	if value, ok := r.lookup(key); ok {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			r.fail(key, err)
+			return
+		}
+		*target = Duration(parsed)
+	}
+}
@@ -2,38 +2,141 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func DbInstance() *mongo.Client {
-	uri := "mongodb://localhost:27017/"
-
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
+// Connect opens a client and pings the server, retrying with a growing
+// backoff while the server is unreachable. It gives up when ctx ends or the
+// retries run out.
+func Connect(ctx context.Context, config Config) (*mongo.Client, error) {
+	clientOptions, err := clientOptions(config)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	ctx,cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	backoff := time.Duration(config.RetryBackoff)
+	for attempt := 0; ; attempt++ {
+		client, err := connectOnce(ctx, config, clientOptions)
+		if err == nil {
+			fmt.Println("db connected..")
+			return client, nil
+		}
+		if attempt >= config.ConnectRetries {
+			return nil, fmt.Errorf("cannot connect to the database after %d attempts: %w", attempt+1, err)
+		}
+
+		log.Printf("cannot connect to the database, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if maxBackoff := time.Duration(config.MaxRetryBackoff); maxBackoff > 0 && backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func connectOnce(ctx context.Context, config Config, clientOptions *options.ClientOptions) (*mongo.Client, error) {
+	timeout := time.Duration(config.ConnectTimeout)
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	connectCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	err = client.Connect(ctx)
+	client, err := mongo.Connect(connectCtx, clientOptions)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	// Connect does not wait for the server, the ping does
+	if err := client.Ping(connectCtx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	return client, nil
+}
+
+func clientOptions(config Config) (*options.ClientOptions, error) {
+	clientOptions := options.Client().ApplyURI(config.URI).SetRegistry(Registry)
+	if err := clientOptions.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(config.MinPoolSize)
+	}
+	if config.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(config.MaxPoolSize)
+	}
+	if config.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(time.Duration(config.MaxConnIdleTime))
+	}
+	if config.ConnectTimeout > 0 {
+		clientOptions.SetConnectTimeout(time.Duration(config.ConnectTimeout))
+	}
+	if config.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(time.Duration(config.ServerSelectionTimeout))
+	}
+	if config.SocketTimeout > 0 {
+		clientOptions.SetSocketTimeout(time.Duration(config.SocketTimeout))
 	}
 
-	fmt.Println("db connected..")
+	if config.Auth.Username != "" || config.Auth.Mechanism != "" {
+		clientOptions.SetAuth(options.Credential{
+			Username:      config.Auth.Username,
+			Password:      config.Auth.Password,
+			PasswordSet:   config.Auth.Password != "",
+			AuthSource:    config.Auth.Source,
+			AuthMechanism: config.Auth.Mechanism,
+		})
+	}
+
+	if config.TLS.Enabled {
+		tlsConfig, err := tlsConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
 
-	return client
+	return clientOptions, nil
 }
 
-var Client *mongo.Client = DbInstance()
+func tlsConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.Insecure,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
 
-func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	collection  := client.Database("restaurant").Collection(collectionName) 
-	return collection
-}
\ No newline at end of file
+	if config.CertificateKeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertificateKeyFile, config.CertificateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+)
+
+// Registry encodes model structs under their json names whenever a field has
+// no bson tag, so a stored Invoice has "invoice_number" rather than
+// "invoicenumber" and matches the keys every filter in the controllers uses.
+// Documents written before it keep the old keys until the "json field names"
+// migration renames them.
+var Registry = buildRegistry()
+
+func buildRegistry() *bsoncodec.Registry {
+	structCodec, err := bsoncodec.NewStructCodec(bsoncodec.JSONFallbackStructTagParser)
+	if err != nil {
+		panic(err)
+	}
+
+	builder := bson.NewRegistryBuilder()
+	builder.RegisterDefaultEncoder(reflect.Struct, structCodec)
+	builder.RegisterDefaultDecoder(reflect.Struct, structCodec)
+
+	return builder.Build()
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	transactionsMu        sync.Mutex
+	transactionsChecked   bool
+	transactionsSupported bool
+)
+
+// SupportsTransactions reports whether the connected deployment is a replica
+// set or a sharded cluster. Standalone servers reject multi-document
+// transactions, so callers have to fall back to compensating writes there.
+func SupportsTransactions(ctx context.Context, client *mongo.Client) bool {
+	transactionsMu.Lock()
+	defer transactionsMu.Unlock()
+
+	if transactionsChecked {
+		return transactionsSupported
+	}
+
+	var hello bson.M
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+		if err != nil {
+			return false
+		}
+	}
+
+	_, isReplicaSet := hello["setName"]
+	transactionsSupported = isReplicaSet || hello["msg"] == "isdbgrid"
+	transactionsChecked = true
+
+	return transactionsSupported
+}
+
+// WithTransaction runs fn inside a multi-document transaction when the
+// deployment supports one. On a standalone server fn still runs inside a
+// session but without a transaction, and it is up to fn to undo its own
+// writes when it fails part way through. Called from inside fn, it joins the
+// session already running instead of starting one of its own.
+func WithTransaction(ctx context.Context, client *mongo.Client, fn func(sessCtx mongo.SessionContext) error) error {
+	if sessCtx, ok := ctx.(mongo.SessionContext); ok {
+		return fn(sessCtx)
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	if !SupportsTransactions(ctx, client) {
+		return mongo.WithSession(ctx, session, fn)
+	}
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
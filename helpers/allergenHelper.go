@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+)
+
+var knownAllergens = labelSet(
+	models.AllergenCelery,
+	models.AllergenGluten,
+	models.AllergenCrustaceans,
+	models.AllergenEggs,
+	models.AllergenFish,
+	models.AllergenLupin,
+	models.AllergenMilk,
+	models.AllergenMolluscs,
+	models.AllergenMustard,
+	models.AllergenTreeNuts,
+	models.AllergenPeanuts,
+	models.AllergenSesame,
+	models.AllergenSoya,
+	models.AllergenSulphites,
+)
+
+var knownDietaryTags = labelSet(
+	models.DietaryVegan,
+	models.DietaryVegetarian,
+	models.DietaryHalal,
+	models.DietaryKosher,
+	models.DietaryGlutenFree,
+	models.DietaryDairyFree,
+	models.DietaryNutFree,
+)
+
+// dietaryConflicts lists the allergens a food tagged with the diet cannot
+// contain.
+var dietaryConflicts = map[string][]string{
+	models.DietaryVegan:      {models.AllergenMilk, models.AllergenEggs, models.AllergenFish, models.AllergenCrustaceans, models.AllergenMolluscs},
+	models.DietaryVegetarian: {models.AllergenFish, models.AllergenCrustaceans, models.AllergenMolluscs},
+	models.DietaryGlutenFree: {models.AllergenGluten},
+	models.DietaryDairyFree:  {models.AllergenMilk},
+	models.DietaryNutFree:    {models.AllergenTreeNuts, models.AllergenPeanuts},
+}
+
+func labelSet(labels ...string) map[string]bool {
+	set := map[string]bool{}
+	for _, label := range labels {
+		set[label] = true
+	}
+	return set
+}
+
+// UniqueLabels sorts the labels and drops duplicates, so stored foods list
+// their allergens and tags the same way however they were entered. Labels
+// that were never given stay nil, an empty list declares there are none.
+func UniqueLabels(labels []string) []string {
+	if labels == nil {
+		return nil
+	}
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, label := range labels {
+		if !seen[label] {
+			seen[label] = true
+			unique = append(unique, label)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// CheckDietaryTags makes sure no dietary tag contradicts the food's
+// allergens, a vegan dish declaring milk is a data entry mistake that a
+// guest with an allergy must not pay for.
+func CheckDietaryTags(allergens []string, tags []string) error {
+	contains := labelSet(allergens...)
+	for _, tag := range tags {
+		for _, allergen := range dietaryConflicts[tag] {
+			if contains[allergen] {
+				return fmt.Errorf("a %s food cannot contain %s", tag, allergen)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseAllergens reads a comma separated allergen list from a query string.
+func ParseAllergens(query string) ([]string, error) {
+	return parseLabels(query, knownAllergens, "allergen")
+}
+
+// ParseDietaryTags reads a comma separated dietary tag list from a query
+// string.
+func ParseDietaryTags(query string) ([]string, error) {
+	return parseLabels(query, knownDietaryTags, "dietary tag")
+}
+
+func parseLabels(query string, known map[string]bool, kind string) ([]string, error) {
+	labels := []string{}
+	for _, label := range strings.Split(query, ",") {
+		label = strings.ToUpper(strings.TrimSpace(label))
+		if label == "" {
+			continue
+		}
+		if !known[label] {
+			return nil, fmt.Errorf("unknown %s %s", kind, label)
+		}
+		labels = append(labels, label)
+	}
+	return UniqueLabels(labels), nil
+}
@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+)
+
+var courseRanks = map[string]int{
+	models.CourseStarter: 1,
+	models.CourseMain:    2,
+	models.CourseDessert: 3,
+}
+
+// CourseRank orders courses as they are served. Items without a course, like
+// drinks, rank zero.
+func CourseRank(course *string) int {
+	if course == nil {
+		return 0
+	}
+	return courseRanks[*course]
+}
+
+// AssignFiring fires items without a course and the earliest course of the
+// batch straight away and holds every later course until a waiter fires it.
+// heldFrom is the rank of the earliest course the order already holds, zero
+// when it holds none, so a batch added later never overtakes that course:
+// it and every course after it are held too.
+func AssignFiring(orderItems []models.OrderItem, heldFrom int, now time.Time) {
+	first := 0
+	for _, orderItem := range orderItems {
+		rank := CourseRank(orderItem.Course)
+		if rank > 0 && (first == 0 || rank < first) {
+			first = rank
+		}
+	}
+
+	for i := range orderItems {
+		rank := CourseRank(orderItems[i].Course)
+		if rank == 0 || (rank == first && (heldFrom == 0 || rank < heldFrom)) {
+			orderItems[i].FireStatus = models.FireStatusFired
+			orderItems[i].FiredAt = &now
+		} else {
+			orderItems[i].FireStatus = models.FireStatusHeld
+			orderItems[i].FiredAt = nil
+		}
+	}
+}
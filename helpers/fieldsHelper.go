@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// fieldTree holds the selected fields by name, a nil subtree selects the
+// whole field.
+type fieldTree map[string]fieldTree
+
+// SelectFields keeps the fields of value named by the comma separated
+// dotted paths of fields, such as `order_id,table.table_number`. Paths go
+// through every element of the arrays on their way and a path naming a
+// document keeps all of it. It returns value as JSON would carry it, or
+// value itself when fields is empty.
+func SelectFields(value interface{}, fields string) (interface{}, error) {
+	if strings.TrimSpace(fields) == "" {
+		return value, nil
+	}
+
+	tree := fieldTree{}
+	for _, path := range strings.Split(fields, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		node := tree
+		keys := strings.Split(path, ".")
+		for i, key := range keys {
+			child, ok := node[key]
+			if ok && child == nil {
+				break
+			}
+			if i == len(keys)-1 {
+				node[key] = nil
+				break
+			}
+			if !ok {
+				child = fieldTree{}
+				node[key] = child
+			}
+			node = child
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+	return selectTree(decoded, tree), nil
+}
+
+func selectTree(value interface{}, tree fieldTree) interface{} {
+	if tree == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		kept := map[string]interface{}{}
+		for key, subtree := range tree {
+			if field, ok := v[key]; ok {
+				kept[key] = selectTree(field, subtree)
+			}
+		}
+		return kept
+	case []interface{}:
+		selected := make([]interface{}, len(v))
+		for i, element := range v {
+			selected[i] = selectTree(element, tree)
+		}
+		return selected
+	}
+	return value
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// giftCardAlphabet leaves out 0, 1, I and O, which are easily misread when a
+// code is typed in from a printed card.
+const giftCardAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// GenerateGiftCardCode returns a random code like 7KQ4-XM2P-9DHT-RW3C.
+func GenerateGiftCardCode() (string, error) {
+	max := big.NewInt(int64(len(giftCardAlphabet)))
+	code := strings.Builder{}
+
+	for i := 0; i < 16; i++ {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(giftCardAlphabet[n.Int64()])
+	}
+
+	return code.String(), nil
+}
+
+// NormalizeGiftCardCode accepts a code typed without dashes or in lower case.
+func NormalizeGiftCardCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	normalized := strings.Builder{}
+	for i, char := range code {
+		if i > 0 && i%4 == 0 {
+			normalized.WriteByte('-')
+		}
+		normalized.WriteRune(char)
+	}
+	return normalized.String()
+}
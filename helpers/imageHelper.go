@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or GIF")
+	ErrImageTooLarge    = errors.New("image is too large")
+)
+
+// maxImagePixels stops decompression bombs, a tiny file can claim to be
+// gigapixels wide.
+const maxImagePixels = 40_000_000
+
+type ProcessedImage struct {
+	ContentType          string
+	Width                int
+	Height               int
+	Thumbnail            []byte
+	ThumbnailContentType string
+	ThumbnailWidth       int
+	ThumbnailHeight      int
+}
+
+// MaxImageBytes is the largest upload accepted, IMAGE_MAX_BYTES or 5 MB.
+func MaxImageBytes() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return 5 << 20
+}
+
+// ThumbnailSize is the longest side of generated thumbnails in pixels,
+// THUMBNAIL_SIZE or 320.
+func ThumbnailSize() int {
+	if value, err := strconv.Atoi(os.Getenv("THUMBNAIL_SIZE")); err == nil && value > 0 {
+		return value
+	}
+	return 320
+}
+
+// ProcessImage checks that data is an image of a supported type, sniffing
+// the content rather than trusting the client's content type, and renders
+// its thumbnail. JPEGs get JPEG thumbnails, the other types keep their
+// transparency in PNG thumbnails.
+func ProcessImage(data []byte) (ProcessedImage, error) {
+	processed := ProcessedImage{ContentType: http.DetectContentType(data)}
+	switch processed.ContentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return processed, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return processed, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return processed, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return processed, ErrUnsupportedImage
+	}
+	processed.Width = img.Bounds().Dx()
+	processed.Height = img.Bounds().Dy()
+
+	thumbnail := Thumbnail(img, ThumbnailSize())
+	processed.ThumbnailWidth = thumbnail.Bounds().Dx()
+	processed.ThumbnailHeight = thumbnail.Bounds().Dy()
+
+	var encoded bytes.Buffer
+	if processed.ContentType == "image/jpeg" {
+		processed.ThumbnailContentType = "image/jpeg"
+		err = jpeg.Encode(&encoded, thumbnail, &jpeg.Options{Quality: 80})
+	} else {
+		processed.ThumbnailContentType = "image/png"
+		err = png.Encode(&encoded, thumbnail)
+	}
+	if err != nil {
+		return processed, err
+	}
+	processed.Thumbnail = encoded.Bytes()
+
+	return processed, nil
+}
+
+// Thumbnail scales img down so its longest side is at most size pixels,
+// averaging the source pixels each thumbnail pixel covers. Smaller images
+// keep their size.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	scaledWidth, scaledHeight := width, height
+	if width >= height && width > size {
+		scaledWidth, scaledHeight = size, height*size/width
+	} else if height > width && height > size {
+		scaledWidth, scaledHeight = width*size/height, size
+	}
+	if scaledWidth < 1 {
+		scaledWidth = 1
+	}
+	if scaledHeight < 1 {
+		scaledHeight = 1
+	}
+
+	thumbnail := image.NewRGBA64(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for ty := 0; ty < scaledHeight; ty++ {
+		y0 := bounds.Min.Y + ty*height/scaledHeight
+		y1 := bounds.Min.Y + (ty+1)*height/scaledHeight
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for tx := 0; tx < scaledWidth; tx++ {
+			x0 := bounds.Min.X + tx*width/scaledWidth
+			x1 := bounds.Min.X + (tx+1)*width/scaledWidth
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := img.At(x, y).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			thumbnail.SetRGBA64(tx, ty, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return thumbnail
+}
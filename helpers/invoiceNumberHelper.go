@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const invoiceNumberPrefix = "INV"
+
+// RestaurantId identifies the restaurant invoices are numbered for. Every
+// restaurant gets its own sequence, so the id is part of the counter key.
+func RestaurantId() string {
+	restaurantId := os.Getenv("RESTAURANT_ID")
+	if restaurantId == "" {
+		restaurantId = "default"
+	}
+	return restaurantId
+}
+
+// FiscalYear returns the year a fiscal year starts in. The first month of the
+// fiscal year comes from FISCAL_YEAR_START_MONTH (1-12) and defaults to January.
+func FiscalYear(t time.Time) int {
+	startMonth, err := strconv.Atoi(os.Getenv("FISCAL_YEAR_START_MONTH"))
+	if err != nil || startMonth < 1 || startMonth > 12 {
+		startMonth = 1
+	}
+
+	if t.Month() < time.Month(startMonth) {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+func InvoiceCounterKey(restaurantId string, fiscalYear int) string {
+	return fmt.Sprintf("invoice:%s:%d", restaurantId, fiscalYear)
+}
+
+func FormatInvoiceNumber(fiscalYear int, seq int64) string {
+	return fmt.Sprintf("%s-%d-%06d", invoiceNumberPrefix, fiscalYear, seq)
+}
+
+// NextSequence atomically increments the counter stored under key and returns
+// the new value, creating the counter on first use.
+func NextSequence(ctx context.Context, counterCollection repositories.Collection, key string) (int64, error) {
+	filter := bson.D{{Key: "_id", Value: key}}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: 1}}}}
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	counter := models.Counter{}
+	if err := counterCollection.FindOneAndUpdate(ctx, filter, update, opt).Decode(&counter); err != nil {
+		return 0, err
+	}
+
+	return counter.Seq, nil
+}
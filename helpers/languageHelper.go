@@ -0,0 +1,242 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxLanguageChain bounds how many languages of an Accept-Language header
+// are tried, every one of them adds to the localizing expressions.
+const maxLanguageChain = 8
+
+var languageTag = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// DefaultLanguage is the language menus and foods are entered in, their
+// untranslated name and description. It comes from DEFAULT_LANGUAGE and
+// defaults to English.
+func DefaultLanguage() string {
+	language, err := NormalizeLanguage(os.Getenv("DEFAULT_LANGUAGE"))
+	if err != nil || language == "" {
+		return "en"
+	}
+	return language
+}
+
+// SupportedLanguages are the languages from SUPPORTED_LANGUAGES, a comma
+// separated list, that the menus are expected to be translated into.
+func SupportedLanguages() []string {
+	languages := []string{}
+	seen := map[string]bool{DefaultLanguage(): true}
+	for _, tag := range strings.Split(os.Getenv("SUPPORTED_LANGUAGES"), ",") {
+		language, err := NormalizeLanguage(tag)
+		if err != nil || language == "" || seen[language] {
+			continue
+		}
+		seen[language] = true
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// NormalizeLanguage lower cases a language tag and checks its shape, so "de-AT"
+// and "de-at" name the same translation.
+func NormalizeLanguage(tag string) (string, error) {
+	language := strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, "_", "-")))
+	if language == "" {
+		return "", nil
+	}
+	if !languageTag.MatchString(language) {
+		return "", fmt.Errorf("invalid language %s", tag)
+	}
+	return language, nil
+}
+
+// LanguageChain lists the languages to try in order: the `lang` query
+// parameter, then the Accept-Language header by preference, each regional
+// tag followed by its base language, and last the default language.
+func LanguageChain(lang string, acceptLanguage string) ([]string, error) {
+	chain := []string{}
+	seen := map[string]bool{}
+	add := func(language string) {
+		for _, candidate := range []string{language, strings.Split(language, "-")[0]} {
+			if !seen[candidate] && len(chain) < maxLanguageChain {
+				seen[candidate] = true
+				chain = append(chain, candidate)
+			}
+		}
+	}
+
+	language, err := NormalizeLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	if language != "" {
+		add(language)
+	}
+
+	type preference struct {
+		language string
+		quality  float64
+	}
+	preferences := []preference{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		language, err := NormalizeLanguage(tag)
+		if err != nil || language == "" || language == "*" {
+			continue
+		}
+		quality := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if quality, err = strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			preferences = append(preferences, preference{language, quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+	for _, preference := range preferences {
+		add(preference.language)
+	}
+
+	// the default language is always tried, even when the chain is full
+	defaultLanguage := DefaultLanguage()
+	if !seen[defaultLanguage] {
+		if len(chain) == maxLanguageChain {
+			chain = chain[:maxLanguageChain-1]
+		}
+		chain = append(chain, defaultLanguage)
+	}
+
+	// translations are never tried after the untranslated fields
+	for i, language := range chain {
+		if language == defaultLanguage {
+			return chain[:i+1], nil
+		}
+	}
+	return chain, nil
+}
+
+// Localize picks the name and description for the first language of the
+// chain that has them, each falling back on its own. It returns the language
+// the name was found in.
+func Localize(chain []string, name string, description *string, translations map[string]models.Translation) (string, *string, string) {
+	defaultLanguage := DefaultLanguage()
+
+	localizedName, nameLanguage := name, defaultLanguage
+	for _, language := range chain {
+		if language == defaultLanguage {
+			break
+		}
+		if translation, ok := translations[language]; ok && translation.Name != nil && *translation.Name != "" {
+			localizedName, nameLanguage = *translation.Name, language
+			break
+		}
+	}
+
+	localizedDescription := description
+	for _, language := range chain {
+		if language == defaultLanguage {
+			break
+		}
+		if translation, ok := translations[language]; ok && translation.Description != nil && *translation.Description != "" {
+			localizedDescription = translation.Description
+			break
+		}
+	}
+
+	return localizedName, localizedDescription, nameLanguage
+}
+
+// LocalizeStage is the aggregation counterpart of Localize. It replaces the
+// name and description of the documents reaching it with their localized
+// values and adds the language the name was found in.
+func LocalizeStage(chain []string) bson.D {
+	defaultLanguage := DefaultLanguage()
+
+	name := interface{}("$name")
+	description := interface{}("$description")
+	language := interface{}(defaultLanguage)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i] == defaultLanguage {
+			continue
+		}
+		translatedName := "$translations." + chain[i] + ".name"
+		translatedDescription := "$translations." + chain[i] + ".description"
+		name = bson.D{{Key: "$ifNull", Value: bson.A{translatedName, name}}}
+		description = bson.D{{Key: "$ifNull", Value: bson.A{translatedDescription, description}}}
+		language = bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$gt", Value: bson.A{translatedName, nil}}}, chain[i], language,
+		}}}
+	}
+
+	return bson.D{{Key: "$addFields", Value: bson.D{
+		{Key: "name", Value: name},
+		{Key: "description", Value: description},
+		{Key: "language", Value: language},
+	}}}
+}
+
+// MissingTranslations lists the languages a menu or food still needs a
+// translated name for, or a translated description when it has one.
+func MissingTranslations(languages []string, description *string, translations map[string]models.Translation) []string {
+	missing := []string{}
+	for _, language := range languages {
+		translation := translations[language]
+		if translation.Name == nil || *translation.Name == "" {
+			missing = append(missing, language)
+			continue
+		}
+		if description != nil && *description != "" && (translation.Description == nil || *translation.Description == "") {
+			missing = append(missing, language)
+		}
+	}
+	return missing
+}
+
+// PrepareTranslations normalizes the language tags translations are keyed
+// by, drops empty texts and refuses translations in the default language,
+// that is what the untranslated fields are for.
+func PrepareTranslations(translations map[string]models.Translation) (map[string]models.Translation, error) {
+	if translations == nil {
+		return nil, nil
+	}
+
+	prepared := map[string]models.Translation{}
+	for tag, translation := range translations {
+		language, err := NormalizeLanguage(tag)
+		if err != nil {
+			return nil, err
+		}
+		if language == "" {
+			return nil, errors.New("translation without a language")
+		}
+		if language == DefaultLanguage() {
+			return nil, fmt.Errorf("%s is the default language, set the name and description instead", language)
+		}
+		if _, ok := prepared[language]; ok {
+			return nil, fmt.Errorf("more than one translation for %s", language)
+		}
+		// an empty text is no translation, the fallback has to kick in
+		if translation.Name != nil && *translation.Name == "" {
+			translation.Name = nil
+		}
+		if translation.Description != nil && *translation.Description == "" {
+			translation.Description = nil
+		}
+		prepared[language] = translation
+	}
+	return prepared, nil
+}
@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"math"
+	"os"
+	"strconv"
+)
+
+// LoyaltyPointsPerUnit is how many points a guest earns for every unit of
+// currency paid, set with LOYALTY_POINTS_PER_UNIT.
+func LoyaltyPointsPerUnit() float64 {
+	return envFloat("LOYALTY_POINTS_PER_UNIT", 1)
+}
+
+// LoyaltyPointValue is what one point is worth when it is redeemed, set with
+// LOYALTY_POINT_VALUE.
+func LoyaltyPointValue() float64 {
+	return envFloat("LOYALTY_POINT_VALUE", 0.01)
+}
+
+func PointsEarned(amount float64) int {
+	return int(math.Floor(amount * LoyaltyPointsPerUnit()))
+}
+
+func PointsValue(points int) float64 {
+	return RoundPrice(float64(points) * LoyaltyPointValue())
+}
+
+// PointsNeeded returns how many points cover amount, rounded up so the
+// amount is always paid in full.
+func PointsNeeded(amount float64) int {
+	return int(math.Ceil(RoundPrice(amount)/LoyaltyPointValue() - 1e-9))
+}
+
+func envFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
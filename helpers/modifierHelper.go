@@ -0,0 +1,117 @@
+package helpers
+
+import (
+	"fmt"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PrepareModifierGroups assigns ids to new modifier groups and options and
+// checks that every group's choice limits can actually be satisfied. A max of
+// zero means a guest may pick every option in the group.
+func PrepareModifierGroups(groups []models.ModifierGroup) error {
+	for i := range groups {
+		group := &groups[i]
+
+		if group.ModifierGroupId == "" {
+			group.ModifierGroupId = primitive.NewObjectID().Hex()
+		}
+		if group.Required && group.MinChoices == 0 {
+			group.MinChoices = 1
+		}
+		if group.MaxChoices == 0 {
+			group.MaxChoices = len(group.Options)
+		}
+
+		if group.MaxChoices > len(group.Options) {
+			return fmt.Errorf("modifier group %s allows more choices than it has options", *group.Name)
+		}
+		if group.MinChoices > group.MaxChoices {
+			return fmt.Errorf("modifier group %s requires more choices than it allows", *group.Name)
+		}
+
+		for j := range group.Options {
+			option := &group.Options[j]
+			if option.OptionId == "" {
+				option.OptionId = primitive.NewObjectID().Hex()
+			}
+			priceDelta := 0.0
+			if option.PriceDelta != nil {
+				priceDelta = RoundPrice(*option.PriceDelta)
+			}
+			option.PriceDelta = &priceDelta
+			option.Allergens = UniqueLabels(option.Allergens)
+		}
+	}
+
+	return nil
+}
+
+// ResolveModifiers checks the selected modifiers against the food's modifier
+// groups and fills in names and price deltas from the food, so the client
+// cannot choose its own prices. It returns the resolved selection and the sum
+// of its price deltas.
+func ResolveModifiers(food models.Food, selected []models.SelectedModifier) ([]models.SelectedModifier, float64, error) {
+	groups := map[string]models.ModifierGroup{}
+	for _, group := range food.ModifierGroups {
+		groups[group.ModifierGroupId] = group
+	}
+
+	resolved := []models.SelectedModifier{}
+	choices := map[string]int{}
+	seen := map[string]bool{}
+	total := 0.0
+
+	for _, selection := range selected {
+		group, ok := groups[selection.ModifierGroupId]
+		if !ok {
+			return nil, 0, fmt.Errorf("modifier group %s does not belong to food %s", selection.ModifierGroupId, food.FoodId)
+		}
+
+		var option *models.ModifierOption
+		for i := range group.Options {
+			if group.Options[i].OptionId == selection.OptionId {
+				option = &group.Options[i]
+				break
+			}
+		}
+		if option == nil {
+			return nil, 0, fmt.Errorf("option %s does not belong to modifier group %s", selection.OptionId, *group.Name)
+		}
+
+		key := group.ModifierGroupId + ":" + option.OptionId
+		if seen[key] {
+			return nil, 0, fmt.Errorf("option %s was selected more than once", *option.Name)
+		}
+		seen[key] = true
+		choices[group.ModifierGroupId]++
+
+		priceDelta := 0.0
+		if option.PriceDelta != nil {
+			priceDelta = *option.PriceDelta
+		}
+		total += priceDelta
+
+		resolved = append(resolved, models.SelectedModifier{
+			ModifierGroupId: group.ModifierGroupId,
+			OptionId:        option.OptionId,
+			GroupName:       *group.Name,
+			Name:            *option.Name,
+			PriceDelta:      priceDelta,
+			Allergens:       option.Allergens,
+		})
+	}
+
+	for _, group := range food.ModifierGroups {
+		count := choices[group.ModifierGroupId]
+		if count < group.MinChoices {
+			return nil, 0, fmt.Errorf("modifier group %s requires at least %d choice(s)", *group.Name, group.MinChoices)
+		}
+		if group.MaxChoices > 0 && count > group.MaxChoices {
+			return nil, 0, fmt.Errorf("modifier group %s allows at most %d choice(s)", *group.Name, group.MaxChoices)
+		}
+	}
+
+	return resolved, RoundPrice(total), nil
+}
@@ -0,0 +1,104 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func RoundPrice(num float64) float64 {
+	return math.Round(num*100) / 100
+}
+
+// PrepareSizePrices rounds the per-size prices and rejects a size that is
+// priced twice.
+func PrepareSizePrices(sizePrices []models.SizePrice) error {
+	seen := map[string]bool{}
+	for i := range sizePrices {
+		sizePrice := &sizePrices[i]
+		if seen[*sizePrice.Size] {
+			return fmt.Errorf("size %s is priced more than once", *sizePrice.Size)
+		}
+		seen[*sizePrice.Size] = true
+
+		price := RoundPrice(*sizePrice.Price)
+		sizePrice.Price = &price
+	}
+	return nil
+}
+
+// PortionPrice returns what one portion of the food costs in the given size.
+// Foods without size prices only come in one portion, priced at Food.Price.
+func PortionPrice(food models.Food, size *string) (float64, error) {
+	if len(food.SizePrices) == 0 {
+		if size != nil && *size != "" {
+			return 0, fmt.Errorf("food %s does not come in sizes", food.FoodId)
+		}
+		return *food.Price, nil
+	}
+
+	if size == nil || *size == "" {
+		return 0, fmt.Errorf("food %s needs a portion_size", food.FoodId)
+	}
+	for _, sizePrice := range food.SizePrices {
+		if *sizePrice.Size == *size {
+			return *sizePrice.Price, nil
+		}
+	}
+	return 0, fmt.Errorf("food %s does not come in size %s", food.FoodId, *size)
+}
+
+// PrepareBundleSlots assigns ids to new bundle slots.
+func PrepareBundleSlots(slots []models.BundleSlot) {
+	for i := range slots {
+		if slots[i].SlotId == "" {
+			slots[i].SlotId = primitive.NewObjectID().Hex()
+		}
+	}
+}
+
+// ResolveBundleChoices matches the guest's choices to the bundle's slots and
+// returns exactly one choice per slot, in slot order. A slot that offers a
+// single food may be left out and is filled with that food.
+func ResolveBundleChoices(bundle models.Bundle, choices []models.BundleChoice) ([]models.BundleChoice, error) {
+	bySlot := map[string]models.BundleChoice{}
+	for _, choice := range choices {
+		if _, ok := bySlot[choice.SlotId]; ok {
+			return nil, fmt.Errorf("slot %s was chosen more than once", choice.SlotId)
+		}
+		bySlot[choice.SlotId] = choice
+	}
+
+	resolved := []models.BundleChoice{}
+	for _, slot := range bundle.Slots {
+		choice, ok := bySlot[slot.SlotId]
+		if !ok {
+			if len(slot.FoodIds) != 1 {
+				return nil, fmt.Errorf("bundle %s needs a choice for %s", *bundle.Name, *slot.Name)
+			}
+			choice = models.BundleChoice{SlotId: slot.SlotId, FoodId: slot.FoodIds[0]}
+		}
+		delete(bySlot, slot.SlotId)
+
+		offered := false
+		for _, foodId := range slot.FoodIds {
+			if foodId == choice.FoodId {
+				offered = true
+				break
+			}
+		}
+		if !offered {
+			return nil, fmt.Errorf("food %s is not offered for %s", choice.FoodId, *slot.Name)
+		}
+
+		resolved = append(resolved, choice)
+	}
+
+	for slotId := range bySlot {
+		return nil, fmt.Errorf("slot %s does not belong to bundle %s", slotId, *bundle.Name)
+	}
+
+	return resolved, nil
+}
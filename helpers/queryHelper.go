@@ -0,0 +1,358 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FieldType tells how the query values of a list field are read.
+type FieldType int
+
+const (
+	StringField FieldType = iota
+	// TextField matches any part of the value, ignoring case.
+	TextField
+	NumberField
+	TimeField
+	BoolField
+)
+
+// ListFields are the fields a list endpoint can be filtered and sorted on.
+type ListFields map[string]FieldType
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor, start over from the first page")
+
+var listOperators = map[string]string{
+	"eq":  "$eq",
+	"ne":  "$ne",
+	"gt":  "$gt",
+	"gte": "$gte",
+	"lt":  "$lt",
+	"lte": "$lte",
+	"in":  "$in",
+	"nin": "$nin",
+}
+
+// ListQuery is what a list request asks for: filters such as
+// `status=PAID` or `created_at[gte]=2024-01-01`, a sort such as
+// `-created_at,name`, and a page of `limit` documents that starts at
+// `offset` or where the `cursor` of the page before left off.
+type ListQuery struct {
+	Filter bson.D
+	Sort   bson.D
+	Limit  int
+	Offset int
+	// PageStages run on the documents of the page only, once they are
+	// sorted and cut, such as the lookups of their relations.
+	PageStages []bson.D
+	// Facets are more $facet branches next to the page and the total, such
+	// as counts of the values of a field.
+	Facets bson.D
+	// after matches the documents past the cursor, nil without one
+	after bson.D
+}
+
+// ParseListQuery reads a ListQuery from the query parameters. Parameters
+// that name no field are left for the endpoint to read.
+func ParseListQuery(values url.Values, fields ListFields, defaultSort string) (ListQuery, error) {
+	query := ListQuery{Filter: bson.D{}, Limit: DefaultListLimit}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxListLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", MaxListLimit)
+		}
+		query.Limit = limit
+	}
+	if value := values.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return query, errors.New("offset must be a positive number")
+		}
+		query.Offset = offset
+	}
+
+	sortSpec := values.Get("sort")
+	if sortSpec == "" {
+		sortSpec = defaultSort
+	}
+	var err error
+	if query.Sort, err = parseSort(sortSpec, fields); err != nil {
+		return query, err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conditions := map[string]bson.D{}
+	filtered := []string{}
+	for _, key := range keys {
+		field, operator := key, "eq"
+		if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+			field, operator = key[:i], key[i+1:len(key)-1]
+		}
+		fieldType, ok := fields[field]
+		if !ok {
+			continue
+		}
+		condition, err := listCondition(field, fieldType, operator, values[key])
+		if err != nil {
+			return query, err
+		}
+		if _, ok := conditions[field]; !ok {
+			filtered = append(filtered, field)
+		}
+		conditions[field] = append(conditions[field], condition...)
+	}
+	for _, field := range filtered {
+		query.Filter = append(query.Filter, bson.E{Key: field, Value: conditions[field]})
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		if query.Offset > 0 {
+			return query, errors.New("page with either offset or cursor")
+		}
+		if query.after, err = decodeCursor(cursor, query.Sort); err != nil {
+			return query, err
+		}
+	}
+
+	return query, nil
+}
+
+// parseSort reads a comma separated list of fields, descending when they
+// start with a minus. _id settles ties, so every document has one place in
+// the order and cursors never skip or repeat one.
+func parseSort(spec string, fields ListFields) (bson.D, error) {
+	order := bson.D{}
+	seen := map[string]bool{}
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		direction := 1
+		if strings.HasPrefix(field, "-") {
+			field, direction = field[1:], -1
+		}
+		if field == "" || seen[field] {
+			continue
+		}
+		if _, ok := fields[field]; !ok && field != "_id" {
+			return nil, fmt.Errorf("cannot sort on %s", field)
+		}
+		seen[field] = true
+		order = append(order, bson.E{Key: field, Value: direction})
+	}
+	if !seen["_id"] {
+		order = append(order, bson.E{Key: "_id", Value: 1})
+	}
+	return order, nil
+}
+
+func listCondition(field string, fieldType FieldType, operator string, raw []string) (bson.D, error) {
+	mongoOperator, ok := listOperators[operator]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator %s on %s", operator, field)
+	}
+
+	if operator == "eq" && len(raw) > 1 {
+		operator, mongoOperator, raw = "in", "$in", []string{strings.Join(raw, ",")}
+	}
+	if operator == "in" || operator == "nin" {
+		values := bson.A{}
+		for _, value := range strings.Split(raw[0], ",") {
+			parsed, err := parseListValue(field, fieldType, value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, parsed)
+		}
+		return bson.D{{Key: mongoOperator, Value: values}}, nil
+	}
+
+	if fieldType == TextField && operator == "eq" && raw[0] != "null" {
+		return bson.D{
+			{Key: "$regex", Value: regexp.QuoteMeta(raw[0])},
+			{Key: "$options", Value: "i"},
+		}, nil
+	}
+	value, err := parseListValue(field, fieldType, raw[0])
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: mongoOperator, Value: value}}, nil
+}
+
+// parseListValue reads a filter value, null matches a missing value.
+func parseListValue(field string, fieldType FieldType, value string) (interface{}, error) {
+	if value == "null" {
+		return nil, nil
+	}
+	switch fieldType {
+	case NumberField:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", field)
+		}
+		return number, nil
+	case TimeField:
+		if date, err := time.Parse(time.RFC3339, value); err == nil {
+			return date, nil
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a RFC 3339 time or a date", field)
+		}
+		return date, nil
+	case BoolField:
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false", field)
+		}
+		return flag, nil
+	}
+	return value, nil
+}
+
+// Pipeline pages through the documents matching the filter, after stages
+// have run on them. It yields one document with the page in `data`, the
+// number of all matching documents in `total` and the Facets next to them.
+// The documents are sorted before they branch out, so `data` only skips to
+// the cursor or offset and cuts the page. The page holds a document more
+// than the limit when there is another page, Page takes it off again.
+func (query ListQuery) Pipeline(stages ...bson.D) mongo.Pipeline {
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: query.Filter}}}
+	pipeline = append(pipeline, stages...)
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: query.Sort}})
+
+	page := bson.A{}
+	if query.after != nil {
+		page = append(page, bson.D{{Key: "$match", Value: query.after}})
+	}
+	page = append(page,
+		bson.D{{Key: "$skip", Value: query.Offset}},
+		bson.D{{Key: "$limit", Value: query.Limit + 1}},
+	)
+	for _, stage := range query.PageStages {
+		page = append(page, stage)
+	}
+
+	branches := bson.D{
+		{Key: "data", Value: page},
+		{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+	}
+	branches = append(branches, query.Facets...)
+	return append(pipeline, bson.D{{Key: "$facet", Value: branches}})
+}
+
+// Page cuts the documents Pipeline found down to the limit and returns the
+// cursor of the next page, empty on the last one.
+func (query ListQuery) Page(documents []primitive.M) ([]primitive.M, string, error) {
+	if len(documents) <= query.Limit {
+		return documents, "", nil
+	}
+	documents = documents[:query.Limit]
+
+	last := documents[len(documents)-1]
+	values := bson.A{}
+	for _, field := range query.Sort {
+		values = append(values, fieldValue(last, field.Key))
+	}
+	cursor, err := bson.Marshal(listCursor{Sort: sortKey(query.Sort), Values: values})
+	if err != nil {
+		return nil, "", err
+	}
+	return documents, base64.RawURLEncoding.EncodeToString(cursor), nil
+}
+
+type listCursor struct {
+	Sort   string `bson:"sort"`
+	Values bson.A `bson:"values"`
+}
+
+// sortKey writes the order back the way `sort` takes it, so a cursor can
+// tell whether it was made for the same order.
+func sortKey(order bson.D) string {
+	fields := make([]string, len(order))
+	for i, field := range order {
+		fields[i] = field.Key
+		if field.Value == -1 {
+			fields[i] = "-" + field.Key
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func fieldValue(document primitive.M, path string) interface{} {
+	var value interface{} = document
+	for _, key := range strings.Split(path, ".") {
+		embedded, ok := value.(primitive.M)
+		if !ok {
+			return nil
+		}
+		value = embedded[key]
+	}
+	return value
+}
+
+// decodeCursor turns a cursor into a filter for the documents that come
+// after it in order: those past its value on the first field, those equal
+// on the first and past it on the second, and so on. Missing values sort
+// first, as Mongo sorts them.
+func decodeCursor(encoded string, order bson.D) (bson.D, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursor := listCursor{}
+	if err := bson.Unmarshal(raw, &cursor); err != nil || len(cursor.Values) != len(order) {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sortKey(order) {
+		return nil, errors.New("the cursor was made for another sort")
+	}
+
+	after := bson.A{}
+	for i, field := range order {
+		clause := bson.D{}
+		for j := 0; j < i; j++ {
+			clause = append(clause, bson.E{Key: order[j].Key, Value: cursor.Values[j]})
+		}
+
+		value := cursor.Values[i]
+		switch {
+		case field.Value == 1 && value == nil:
+			clause = append(clause, bson.E{Key: field.Key, Value: bson.D{{Key: "$ne", Value: nil}}})
+		case field.Value == 1:
+			clause = append(clause, bson.E{Key: field.Key, Value: bson.D{{Key: "$gt", Value: value}}})
+		case value == nil:
+			// nothing sorts below a missing value
+			continue
+		default:
+			// $not also lets the documents missing the field through
+			clause = append(clause, bson.E{Key: field.Key, Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: value}}}}})
+		}
+		after = append(after, clause)
+	}
+	if len(after) == 0 {
+		return bson.D{{Key: "_id", Value: bson.D{{Key: "$exists", Value: false}}}}, nil
+	}
+	return bson.D{{Key: "$or", Value: after}}, nil
+}
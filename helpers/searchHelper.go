@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldText lower cases text and strips its accents, so "creme brulee" finds
+// "Crème brûlée".
+func FoldText(text string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+	if err != nil {
+		folded = text
+	}
+	return strings.ToLower(folded)
+}
+
+// MaxTypos is how many typos a type-ahead query of the given length may
+// contain. Short queries must be typed right, almost any word is one typo
+// away from a two letter query.
+func MaxTypos(queryLength int) int {
+	switch {
+	case queryLength <= 3:
+		return 0
+	case queryLength <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// PrefixDistance is the fewest edits that turn query into a prefix of word.
+// Both are expected to be folded already.
+func PrefixDistance(query string, word string) int {
+	q, w := []rune(query), []rune(word)
+
+	// row[j] is the distance between the query read so far and w[:j]
+	previous2 := make([]int, len(w)+1)
+	previous := make([]int, len(w)+1)
+	row := make([]int, len(w)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(q); i++ {
+		row[0] = i
+		for j := 1; j <= len(w); j++ {
+			cost := 1
+			if q[i-1] == w[j-1] {
+				cost = 0
+			}
+			row[j] = min3(previous[j]+1, row[j-1]+1, previous[j-1]+cost)
+			// swapped neighbours count as one typo
+			if i > 1 && j > 1 && q[i-1] == w[j-2] && q[i-2] == w[j-1] && previous2[j-2]+1 < row[j] {
+				row[j] = previous2[j-2] + 1
+			}
+		}
+		previous2, previous, row = previous, row, previous2
+	}
+
+	// the rest of the word after the best matching prefix is free
+	best := previous[0]
+	for _, distance := range previous {
+		if distance < best {
+			best = distance
+		}
+	}
+	return best
+}
+
+// WordStartPattern is a regular expression for the names with a word that
+// starts with one of the first letters of query, accents and case ignored.
+// A prefix within maxTypos of the query starts with one of its first
+// maxTypos+1 letters unless the typos all fall there, so the pattern narrows
+// down the names worth handing to PrefixDistance.
+func WordStartPattern(query string, maxTypos int) string {
+	letters := map[rune]bool{}
+	for _, letter := range []rune(query) {
+		if len(letters) > maxTypos {
+			break
+		}
+		if !unicode.IsSpace(letter) {
+			letters[letter] = true
+		}
+	}
+
+	class := []rune{}
+	for letter := range letters {
+		class = append(class, letter)
+	}
+	// the accented letters that fold to one of them, upper case too
+	for letter := rune(0xC0); letter <= 0x17F; letter++ {
+		if folded := []rune(FoldText(string(letter))); len(folded) == 1 && letters[folded[0]] {
+			class = append(class, letter)
+		}
+	}
+	sort.Slice(class, func(i, j int) bool { return class[i] < class[j] })
+
+	pattern := strings.Builder{}
+	pattern.WriteString(`(^|\s)[`)
+	for _, letter := range class {
+		if strings.ContainsRune(`\]^-`, letter) {
+			pattern.WriteRune('\\')
+		}
+		pattern.WriteRune(letter)
+	}
+	pattern.WriteString("]")
+	return pattern.String()
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,93 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+)
+
+// ErrInvalidTableLink is returned for table links that were not signed with
+// the current TABLE_LINK_SECRET.
+var ErrInvalidTableLink = errors.New("invalid table link")
+
+func tableLinkSecret() ([]byte, error) {
+	secret := os.Getenv("TABLE_LINK_SECRET")
+	if secret == "" {
+		return nil, errors.New("TABLE_LINK_SECRET is not set")
+	}
+	return []byte(secret), nil
+}
+
+// tableLinkPayload is what a link signs. Tables whose link was never rotated
+// have no nonce and keep the codes printed before nonces existed.
+func tableLinkPayload(tableId string, nonce string) string {
+	if nonce == "" {
+		return tableId
+	}
+	return tableId + "." + nonce
+}
+
+func tableLinkSignature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewTableLinkNonce returns a random nonce to rotate a table's link with.
+func NewTableLinkNonce() (string, error) {
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce), nil
+}
+
+// SignTableLink returns the token printed on a table's QR code. It is the
+// table id and the table's current link nonce followed by their HMAC, so
+// guests can't swap in another table. Rotating the nonce of a table
+// invalidates its printed code, rotating TABLE_LINK_SECRET every printed code.
+func SignTableLink(tableId string, nonce string) (string, error) {
+	secret, err := tableLinkSecret()
+	if err != nil {
+		return "", err
+	}
+	payload := tableLinkPayload(tableId, nonce)
+	return payload + "." + tableLinkSignature(secret, payload), nil
+}
+
+// VerifyTableLink checks the token's signature and returns its table id and
+// nonce. The caller still has to check the nonce is the table's current one.
+func VerifyTableLink(token string) (string, string, error) {
+	secret, err := tableLinkSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", "", ErrInvalidTableLink
+	}
+	payload, signature := token[:i], token[i+1:]
+	tableId, nonce, _ := strings.Cut(payload, ".")
+	if tableId == "" {
+		return "", "", ErrInvalidTableLink
+	}
+	if !hmac.Equal([]byte(signature), []byte(tableLinkSignature(secret, payload))) {
+		return "", "", ErrInvalidTableLink
+	}
+	return tableId, nonce, nil
+}
+
+// TableLinkURL is the address the QR code points to. GUEST_ORDER_URL is the
+// guest ordering page, the token is appended as the last path segment.
+func TableLinkURL(token string) string {
+	base := os.Getenv("GUEST_ORDER_URL")
+	if base == "" {
+		base = "http://localhost:8000/api/v1/guest"
+	}
+	return strings.TrimSuffix(base, "/") + "/" + token
+}
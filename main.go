@@ -1,34 +1,153 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/RahulMj21/mongo-restaurant-management/database"
 	"github.com/RahulMj21/mongo-restaurant-management/middlewares"
+	"github.com/RahulMj21/mongo-restaurant-management/migrations"
+	"github.com/RahulMj21/mongo-restaurant-management/repositories"
 	"github.com/RahulMj21/mongo-restaurant-management/routes"
+	"github.com/RahulMj21/mongo-restaurant-management/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves the API until SIGINT or SIGTERM. It returns once the requests in
+// flight and the purge are done and the client is disconnected, so failing
+// part way through still cleans up what was started.
+func run() error {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8000"
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	config, err := database.LoadConfig()
+	if err != nil {
+		return err
+	}
+	client, err := database.Connect(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		disconnectCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := client.Disconnect(disconnectCtx); err != nil {
+			log.Printf("cannot disconnect from the database: %v", err)
+		}
+	}()
+
+	db := client.Database(config.Database)
+	// the handlers rely on the unique indexes and on the data the migrations
+	// move, they must not run before them
+	pending, err := migrations.Pending(ctx, db)
+	if err != nil {
+		return fmt.Errorf("cannot check the migrations: %w", err)
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations are pending, run `migrate up` first", pending)
+	}
+	if !database.SupportsTransactions(ctx, client) {
+		log.Printf("%s is not a replica set, invoices cannot be created, see the README", config.URI)
+	}
+	blobs, err := storage.FromEnv(db)
+	if err != nil {
+		return err
+	}
+	h := controllers.NewHandler(repositories.NewMongoRepos(db), blobs)
+
+	// the purge has to stop before the client it writes with disconnects
+	purging := sync.WaitGroup{}
+	purging.Add(1)
+	go func() {
+		defer purging.Done()
+		purgeDeleted(ctx, h)
+	}()
+	defer func() {
+		stop()
+		purging.Wait()
+	}()
+
 	app := gin.New()
+	app.Use(middlewares.Problems)
+	app.NoRoute(middlewares.NoRoute)
 	api := app.Group("/api/v1")
 
 	api.Use(gin.Logger())
 
-	routes.UserRoutes(api)
+	routes.UserRoutes(api, h)
+	routes.GuestRoutes(api, h)
+	routes.ImageContentRoutes(api, h)
 	api.Use(middlewares.Authentication)
 
-	routes.FoodRoutes(api)
-	routes.InvoiceRoutes(api)
-	routes.MenuRoutes(api)
-	routes.OrderItemRoutes(api)
-	routes.OrderRoutes(api)
-	routes.TableRoutes(api)
+	routes.AnalyticsRoutes(api, h)
+	routes.BundleRoutes(api, h)
+	routes.CustomerRoutes(api, h)
+	routes.FoodRoutes(api, h)
+	routes.GiftCardRoutes(api, h)
+	routes.ImageRoutes(api, h)
+	routes.InvoiceRoutes(api, h)
+	routes.KitchenRoutes(api, h)
+	routes.MenuRoutes(api, h)
+	routes.NoteRoutes(api, h)
+	routes.OrderItemRoutes(api, h)
+	routes.OrderRoutes(api, h)
+	routes.SearchRoutes(api, h)
+	routes.TableRoutes(api, h)
+	routes.TranslationRoutes(api, h)
+
+	server := &http.Server{Addr: ":" + port, Handler: app}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	// let the requests in flight finish before the client goes away
+	log.Print("shutting down..")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("cannot shut down gracefully: %w", err)
+	}
+	return nil
+}
 
-	app.Run(":" + port)
+// shutdownTimeout is how long requests in flight get to finish on SIGTERM,
+// SHUTDOWN_TIMEOUT or 30 seconds.
+func shutdownTimeout() time.Duration {
+	return durationFromEnv("SHUTDOWN_TIMEOUT", 30*time.Second)
 }
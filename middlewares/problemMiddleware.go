@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/RahulMj21/mongo-restaurant-management/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+const problemType = "application/problem+json"
+
+// problem is an RFC 7807 problem document.
+type problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+// Problems answers a request whose handler failed with the last error it
+// reported through c.Error. Errors other than *apperrors.Error, and panics,
+// answer 500 and are only logged.
+func Problems(c *gin.Context) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("panic serving %s: %v\n%s", c.Request.URL.Path, recovered, debug.Stack())
+			c.Abort()
+			writeProblem(c, apperrors.Internal(fmt.Errorf("panic: %v", recovered)))
+		}
+	}()
+
+	c.Next()
+
+	if len(c.Errors) > 0 {
+		writeProblem(c, c.Errors.Last().Err)
+	}
+}
+
+// NoRoute answers the paths no route serves.
+func NoRoute(c *gin.Context) {
+	c.Error(apperrors.NotFound("no endpoint serves " + c.Request.Method + " " + c.Request.URL.Path))
+}
+
+func writeProblem(c *gin.Context, err error) {
+	appErr := &apperrors.Error{}
+	if !errors.As(err, &appErr) {
+		appErr = apperrors.Internal(err)
+	}
+	if appErr.Status >= 500 && appErr.Err != nil {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, appErr.Err)
+	}
+	if c.Writer.Written() {
+		log.Printf("%s %s failed after answering: %v", c.Request.Method, c.Request.URL.Path, err)
+		return
+	}
+
+	body, err := json.Marshal(problem{
+		Type:     "/problems/" + appErr.Type,
+		Title:    http.StatusText(appErr.Status),
+		Status:   appErr.Status,
+		Detail:   appErr.Detail,
+		Instance: c.Request.URL.RequestURI(),
+		Errors:   appErr.Fields,
+	})
+	if err != nil {
+		c.Status(appErr.Status)
+		return
+	}
+	c.Data(appErr.Status, problemType, body)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"github.com/RahulMj21/mongo-restaurant-management/migrations"
+)
+
+const migrateUsage = `usage: migrate [command]
+
+commands:
+  up [version]   apply the pending migrations, up to version if given
+  down [steps]   revert the last steps migrations, 1 by default
+  status         list the migrations and when they were applied
+  unlock         remove the lock left by a migration that died`
+
+// migrate runs the `migrate` subcommand against the configured database.
+func migrate(args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	number := 0
+	if len(args) > 1 {
+		value, err := strconv.Atoi(args[1])
+		if err != nil || value < 1 || len(args) > 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+		number = value
+	}
+
+	config, err := database.LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := database.Connect(ctx, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	db := client.Database(config.Database)
+
+	switch command {
+	case "up":
+		ran, err := migrations.Up(ctx, db, number)
+		for _, migration := range ran {
+			fmt.Printf("applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		if len(ran) == 0 {
+			fmt.Println("nothing to apply")
+		}
+	case "down":
+		if number == 0 {
+			number = 1
+		}
+		reverted, err := migrations.Down(ctx, db, number)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("nothing to revert")
+		}
+	case "status":
+		statuses, err := migrations.Statuses(ctx, db)
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-25s  %s\n", status.Version, status.Name, appliedAt)
+		}
+	case "unlock":
+		if err := migrations.Unlock(ctx, db); err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// initialIndexesList makes the public ids and user emails unique and covers
+// the lookups the handlers run on every request.
+var initialIndexesList = []index{
+	{collection: "user", name: "user_email_unique", keys: bson.D{{Key: "email", Value: 1}}, unique: true},
+	{collection: "user", name: "user_id_unique", keys: bson.D{{Key: "user_id", Value: 1}}, unique: true},
+
+	{collection: "menu", name: "menu_id_unique", keys: bson.D{{Key: "menu_id", Value: 1}}, unique: true},
+
+	{collection: "food", name: "food_id_unique", keys: bson.D{{Key: "food_id", Value: 1}}, unique: true},
+	{collection: "food", name: "food_menu_id", keys: bson.D{{Key: "menu_id", Value: 1}}},
+	{collection: "food", name: "food_image_id", keys: bson.D{{Key: "image_id", Value: 1}}},
+
+	{collection: "table", name: "table_id_unique", keys: bson.D{{Key: "table_id", Value: 1}}, unique: true},
+
+	{collection: "order", name: "order_id_unique", keys: bson.D{{Key: "order_id", Value: 1}}, unique: true},
+	{collection: "order", name: "order_table_id_order_date", keys: bson.D{{Key: "table_id", Value: 1}, {Key: "order_date", Value: -1}}},
+	{collection: "order", name: "order_customer_id_order_date", keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "order_date", Value: -1}}},
+	{collection: "order", name: "order_order_date", keys: bson.D{{Key: "order_date", Value: -1}}},
+
+	{collection: "order_item", name: "order_item_id_unique", keys: bson.D{{Key: "order_item_id", Value: 1}}, unique: true},
+	{collection: "order_item", name: "order_item_order_id", keys: bson.D{{Key: "order_id", Value: 1}, {Key: "seat_number", Value: 1}}},
+	{collection: "order_item", name: "order_item_parent_order_item_id", keys: bson.D{{Key: "parent_order_item_id", Value: 1}}},
+	{collection: "order_item", name: "order_item_approval_status", keys: bson.D{{Key: "approval_status", Value: 1}, {Key: "created_at", Value: 1}}},
+
+	{collection: "invoice", name: "invoice_id_unique", keys: bson.D{{Key: "invoice_id", Value: 1}}, unique: true},
+	{collection: "invoice", name: "invoice_order_id", keys: bson.D{{Key: "order_id", Value: 1}}},
+	{
+		collection: "invoice",
+		name:       "invoice_number_unique",
+		keys:       bson.D{{Key: "invoice_number", Value: 1}},
+		unique:     true,
+		// invoices from before numbering have none
+		partial: bson.D{{Key: "invoice_number", Value: bson.D{{Key: "$type", Value: "string"}}}},
+	},
+
+	{collection: "customer", name: "customer_id_unique", keys: bson.D{{Key: "customer_id", Value: 1}}, unique: true},
+	{collection: "customer", name: "customer_phone", keys: bson.D{{Key: "phone", Value: 1}}},
+	{collection: "customer", name: "customer_email", keys: bson.D{{Key: "email", Value: 1}}},
+
+	{collection: "loyalty_transaction", name: "loyalty_transaction_id_unique", keys: bson.D{{Key: "transaction_id", Value: 1}}, unique: true},
+	{collection: "loyalty_transaction", name: "loyalty_transaction_customer_id", keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	{collection: "loyalty_transaction", name: "loyalty_transaction_invoice_id", keys: bson.D{{Key: "invoice_id", Value: 1}}},
+
+	{collection: "gift_card", name: "gift_card_id_unique", keys: bson.D{{Key: "gift_card_id", Value: 1}}, unique: true},
+	{collection: "gift_card", name: "gift_card_code_unique", keys: bson.D{{Key: "code", Value: 1}}, unique: true},
+
+	{collection: "gift_card_transaction", name: "gift_card_transaction_id_unique", keys: bson.D{{Key: "transaction_id", Value: 1}}, unique: true},
+	{collection: "gift_card_transaction", name: "gift_card_transaction_gift_card_id", keys: bson.D{{Key: "gift_card_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	{collection: "gift_card_transaction", name: "gift_card_transaction_invoice_id", keys: bson.D{{Key: "invoice_id", Value: 1}}},
+
+	{collection: "bundle", name: "bundle_id_unique", keys: bson.D{{Key: "bundle_id", Value: 1}}, unique: true},
+	{collection: "bundle", name: "bundle_menu_id", keys: bson.D{{Key: "menu_id", Value: 1}}},
+
+	{collection: "note", name: "note_id_unique", keys: bson.D{{Key: "note_id", Value: 1}}, unique: true},
+	{collection: "note", name: "note_entity", keys: bson.D{{Key: "entity_type", Value: 1}, {Key: "entity_id", Value: 1}}},
+
+	{collection: "image", name: "image_id_unique", keys: bson.D{{Key: "image_id", Value: 1}}, unique: true},
+	// not unique, two uploads of the same bytes may race past the dedupe
+	{collection: "image", name: "image_hash", keys: bson.D{{Key: "hash", Value: 1}}},
+	{collection: "image", name: "image_thumbnail_hash", keys: bson.D{{Key: "thumbnail_hash", Value: 1}}},
+}
+
+var initialIndexes = Migration{
+	Version: 2,
+	Name:    "initial indexes",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		return createIndexes(ctx, db, initialIndexesList)
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		return dropIndexes(ctx, db, initialIndexesList)
+	},
+}
@@ -0,0 +1,124 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// legacyFieldNames maps, per collection, the keys the driver's default struct
+// codec stored untagged fields under to the json names the registry in
+// package database stores them under now. Only the fields of the models from
+// before that registry are listed, later fields were never written the old
+// way. Fields whose json name is their lowercased Go name never moved.
+var legacyFieldNames = map[string]bson.D{
+	"food": {
+		{Key: "foodimage", Value: "food_image"},
+		{Key: "createdat", Value: "created_at"},
+		{Key: "updatedat", Value: "updated_at"},
+		{Key: "foodid", Value: "food_id"},
+		{Key: "menuid", Value: "menu_id"},
+	},
+	"invoice": {
+		{Key: "invoiceid", Value: "invoice_id"},
+		{Key: "orderid", Value: "order_id"},
+		{Key: "paymentmethod", Value: "payment_method"},
+		{Key: "paymentstatus", Value: "payment_status"},
+		{Key: "paymentduedate", Value: "payment_due_date"},
+		{Key: "createdat", Value: "created_at"},
+		{Key: "updatedat", Value: "updated_at"},
+	},
+	"menu": {
+		{Key: "startdate", Value: "start_date"},
+		{Key: "enddate", Value: "end_date"},
+		{Key: "createdat", Value: "created_at"},
+		{Key: "updatedat", Value: "updated_at"},
+		{Key: "menuid", Value: "menu_id"},
+	},
+	"note": {
+		{Key: "createdat", Value: "created_at"},
+		{Key: "updatedat", Value: "updated_at"},
+		{Key: "noteid", Value: "note_id"},
+	},
+	"order": {
+		{Key: "orderdate", Value: "order_date"},
+		{Key: "createdat", Value: "created_at"},
+		{Key: "updatedat", Value: "updated_at"},
+		{Key: "orderid", Value: "order_id"},
+		{Key: "tableid", Value: "table_id"},
+	},
+	"order_item": {
+		{Key: "unitprice", Value: "unit_price"},
+		{Key: "createdat", Value: "created_at"},
+		{Key: "updatedat", Value: "updated_at"},
+		{Key: "foodid", Value: "food_id"},
+		{Key: "orderitemid", Value: "order_item_id"},
+		{Key: "orderid", Value: "order_id"},
+	},
+	"table": {
+		{Key: "numberofguests", Value: "number_of_guests"},
+		{Key: "tablenumber", Value: "table_number"},
+		{Key: "createdat", Value: "created_at"},
+		{Key: "updatedat", Value: "updated_at"},
+		{Key: "tableid", Value: "table_id"},
+	},
+	"user": {
+		{Key: "firstname", Value: "first_name"},
+		{Key: "lastname", Value: "last_name"},
+		{Key: "accesstoken", Value: "access_token"},
+		{Key: "refreshtoken", Value: "refresh_token"},
+		{Key: "createdat", Value: "created_at"},
+		{Key: "updatedat", Value: "updated_at"},
+		{Key: "userid", Value: "user_id"},
+	},
+}
+
+// renameFields renames the keys of every document of collection that still
+// has one of the old names. Running it again finds nothing left to rename.
+func renameFields(ctx context.Context, db *mongo.Database, collection string, renames bson.D) error {
+	stale := bson.A{}
+	for _, rename := range renames {
+		stale = append(stale, bson.D{{Key: rename.Key, Value: bson.D{{Key: "$exists", Value: true}}}})
+	}
+
+	filter := bson.D{{Key: "$or", Value: stale}}
+	update := bson.D{{Key: "$rename", Value: renames}}
+	if _, err := db.Collection(collection).UpdateMany(ctx, filter, update); err != nil {
+		return fmt.Errorf("renaming fields of %s: %w", collection, err)
+	}
+	return nil
+}
+
+func reversed(renames bson.D) bson.D {
+	back := bson.D{}
+	for _, rename := range renames {
+		back = append(back, bson.E{Key: rename.Value.(string), Value: rename.Key})
+	}
+	return back
+}
+
+// jsonFieldNames moves the documents written before the registry to the keys
+// the handlers filter on. It runs before the indexes, the unique ones would
+// otherwise see every legacy document without an id.
+var jsonFieldNames = Migration{
+	Version: 1,
+	Name:    "json field names",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		for collection, renames := range legacyFieldNames {
+			if err := renameFields(ctx, db, collection, renames); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		for collection, renames := range legacyFieldNames {
+			if err := renameFields(ctx, db, collection, reversed(renames)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
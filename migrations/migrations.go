@@ -0,0 +1,291 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one numbered change to the schema. Down undoes what Up did,
+// both must be safe to run again after failing half way.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// All lists the migrations in the order they apply. New ones go at the end
+// with the next version.
+var All = []Migration{
+	jsonFieldNames,
+	initialIndexes,
+	searchIndexes,
+	numericQuantities,
+	roundedBalances,
+}
+
+const collectionName = "schema_migrations"
+
+// lockId is the document that keeps two migrate runs from interleaving.
+const lockId = "lock"
+
+var ErrLocked = errors.New("another migration is running, run `migrate unlock` if it died")
+
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+func applied(ctx context.Context, db *mongo.Database) (map[int]record, error) {
+	cursor, err := db.Collection(collectionName).Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$type", Value: "number"}}}})
+	if err != nil {
+		return nil, err
+	}
+	records := []record{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]record{}
+	for _, r := range records {
+		byVersion[r.Version] = r
+	}
+	return byVersion, nil
+}
+
+// Statuses tells which migrations have been applied.
+func Statuses(ctx context.Context, db *mongo.Database) ([]Status, error) {
+	done, err := applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	for _, migration := range sorted() {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if r, ok := done[migration.Version]; ok {
+			appliedAt := r.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending counts the migrations not applied yet.
+func Pending(ctx context.Context, db *mongo.Database) (int, error) {
+	done, err := applied(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, migration := range All {
+		if _, ok := done[migration.Version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Up applies the pending migrations up to and including target, or all of
+// them when target is 0. It stops at the first failure.
+func Up(ctx context.Context, db *mongo.Database, target int) ([]Migration, error) {
+	ran := []Migration{}
+	err := withLock(ctx, db, func() error {
+		done, err := applied(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range sorted() {
+			if target > 0 && migration.Version > target {
+				break
+			}
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := migration.Up(ctx, db); err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			appliedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			if _, err := db.Collection(collectionName).InsertOne(ctx, record{migration.Version, migration.Name, appliedAt}); err != nil {
+				return err
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func Down(ctx context.Context, db *mongo.Database, steps int) ([]Migration, error) {
+	reverted := []Migration{}
+	err := withLock(ctx, db, func() error {
+		done, err := applied(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		migrations := sorted()
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if err := migration.Down(ctx, db); err != nil {
+				return fmt.Errorf("reverting migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := db.Collection(collectionName).DeleteOne(ctx, bson.D{{Key: "_id", Value: migration.Version}}); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Unlock removes the lock a crashed run left behind.
+func Unlock(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(collectionName).DeleteOne(ctx, bson.D{{Key: "_id", Value: lockId}})
+	return err
+}
+
+func withLock(ctx context.Context, db *mongo.Database, fn func() error) error {
+	lockedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := db.Collection(collectionName).InsertOne(ctx, bson.D{{Key: "_id", Value: lockId}, {Key: "locked_at", Value: lockedAt}})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrLocked
+	}
+	if err != nil {
+		return err
+	}
+	defer Unlock(context.Background(), db)
+
+	return fn()
+}
+
+func sorted() []Migration {
+	migrations := append([]Migration{}, All...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
+
+// index is an index a migration manages, named so Down can drop it again.
+type index struct {
+	collection string
+	name       string
+	keys       bson.D
+	unique     bool
+	// partial limits the index to the documents matching it, so legacy
+	// documents without the field do not collide on a unique index.
+	partial bson.D
+	weights bson.D
+}
+
+func createIndexes(ctx context.Context, db *mongo.Database, indexes []index) error {
+	for _, i := range indexes {
+		if i.unique {
+			if err := checkUnique(ctx, db, i); err != nil {
+				return err
+			}
+		}
+		opts := options.Index().SetName(i.name)
+		if i.unique {
+			opts.SetUnique(true)
+		}
+		if i.partial != nil {
+			opts.SetPartialFilterExpression(i.partial)
+		}
+		if i.weights != nil {
+			opts.SetWeights(i.weights)
+		}
+		if _, err := db.Collection(i.collection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: i.keys, Options: opts}); err != nil {
+			return fmt.Errorf("index %s on %s: %w", i.name, i.collection, err)
+		}
+	}
+	return nil
+}
+
+// maxDuplicates is how many duplicates checkUnique names at most.
+const maxDuplicates = 5
+
+// checkUnique refuses a unique index while documents already share its keys,
+// naming some of them. Building the index would fail on them half way, and
+// which of two users with the same email is the real one is not for a
+// migration to guess.
+func checkUnique(ctx context.Context, db *mongo.Database, i index) error {
+	keys := bson.D{}
+	for _, key := range i.keys {
+		keys = append(keys, bson.E{Key: key.Key, Value: "$" + key.Key})
+	}
+
+	pipeline := mongo.Pipeline{}
+	if i.partial != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: i.partial}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: keys},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+		bson.D{{Key: "$limit", Value: maxDuplicates}},
+	)
+	cursor, err := db.Collection(i.collection).Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("checking %s for duplicates: %w", i.collection, err)
+	}
+	duplicates := []struct {
+		Keys  bson.M `bson:"_id"`
+		Count int    `bson:"count"`
+	}{}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return fmt.Errorf("checking %s for duplicates: %w", i.collection, err)
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	found := []string{}
+	for _, duplicate := range duplicates {
+		values := []string{}
+		for _, key := range i.keys {
+			values = append(values, fmt.Sprint(duplicate.Keys[key.Key]))
+		}
+		found = append(found, fmt.Sprintf("%s (%d times)", strings.Join(values, "/"), duplicate.Count))
+	}
+	return fmt.Errorf("index %s on %s needs unique values, merge or remove the duplicates first: %s",
+		i.name, i.collection, strings.Join(found, ", "))
+}
+
+func dropIndexes(ctx context.Context, db *mongo.Database, indexes []index) error {
+	for _, i := range indexes {
+		_, err := db.Collection(i.collection).Indexes().DropOne(ctx, i.name)
+		var commandErr mongo.CommandError
+		// the index or the whole collection is already gone
+		if errors.As(err, &commandErr) && (commandErr.Code == 26 || commandErr.Code == 27) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("index %s on %s: %w", i.name, i.collection, err)
+		}
+	}
+	return nil
+}
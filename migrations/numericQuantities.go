@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// numericQuantities moves the S, M or L order items used to store as their
+// quantity to their portion size. Each of those items was one portion.
+var numericQuantities = Migration{
+	Version: 4,
+	Name:    "numeric quantities",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		filter := bson.D{{Key: "quantity", Value: bson.D{{Key: "$type", Value: "string"}}}}
+		update := bson.A{bson.D{{Key: "$set", Value: bson.D{
+			{Key: "portion_size", Value: "$quantity"},
+			{Key: "quantity", Value: 1},
+		}}}}
+		_, err := db.Collection("order_item").UpdateMany(ctx, filter, update)
+		return err
+	},
+	// Down can only keep the size, the quantities the old model had no room
+	// for are lost.
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		filter := bson.D{{Key: "portion_size", Value: bson.D{{Key: "$type", Value: "string"}}}}
+		update := bson.A{
+			bson.D{{Key: "$set", Value: bson.D{{Key: "quantity", Value: "$portion_size"}}}},
+			bson.D{{Key: "$unset", Value: "portion_size"}},
+		}
+		_, err := db.Collection("order_item").UpdateMany(ctx, filter, update)
+		return err
+	},
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// roundedBalances rounds the gift card balances left a fraction of a cent off
+// by adding and taking off amounts before every change was rounded.
+var roundedBalances = Migration{
+	Version: 5,
+	Name:    "rounded balances",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		filter := bson.D{{Key: "balance", Value: bson.D{{Key: "$exists", Value: true}}}}
+		update := bson.A{bson.D{{Key: "$set", Value: bson.D{
+			{Key: "balance", Value: bson.D{{Key: "$round", Value: bson.A{"$balance", 2}}}},
+		}}}}
+		_, err := db.Collection("gift_card").UpdateMany(ctx, filter, update)
+		return err
+	},
+	// Down has nothing to do, the rounded balances are what the cards held.
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		return nil
+	},
+}
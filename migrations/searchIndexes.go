@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// searchIndexesList are the text indexes search ranks with. Names weigh more
+// than descriptions.
+var searchIndexesList = []index{
+	{
+		collection: "food",
+		name:       "food_text",
+		keys:       bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+		weights:    bson.D{{Key: "name", Value: 10}, {Key: "description", Value: 2}},
+	},
+	{
+		collection: "menu",
+		name:       "menu_text",
+		keys:       bson.D{{Key: "name", Value: "text"}, {Key: "category", Value: "text"}, {Key: "description", Value: "text"}},
+		weights:    bson.D{{Key: "name", Value: 10}, {Key: "category", Value: 5}, {Key: "description", Value: 2}},
+	},
+}
+
+var searchIndexes = Migration{
+	Version: 3,
+	Name:    "search text indexes",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		return createIndexes(ctx, db, searchIndexesList)
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		return dropIndexes(ctx, db, searchIndexesList)
+	},
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Bundle struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Name      *string            `json:"name" validate:"required,min=2,max=40"`
+	Price     *float64           `json:"price" validate:"required,min=0"`
+	Slots     []BundleSlot       `json:"slots" validate:"required,min=1,dive"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Version   int64              `json:"version"`
+	BundleId  string             `json:"bundle_id"`
+	MenuId    *string            `json:"menu_id" validate:"required"`
+}
+
+type BundleSlot struct {
+	SlotId  string   `json:"slot_id"`
+	Name    *string  `json:"name" validate:"required,min=2,max=40"`
+	FoodIds []string `json:"food_ids" validate:"required,min=1"`
+}
+
+type BundleSelection struct {
+	BundleId   string         `json:"bundle_id" validate:"required"`
+	Quantity   *int           `json:"quantity" validate:"required,min=1"`
+	Course     *string        `json:"course" validate:"omitempty,eq=STARTER|eq=MAIN|eq=DESSERT"`
+	SeatNumber *int           `json:"seat_number" validate:"omitempty,min=1"`
+	Choices    []BundleChoice `json:"choices" validate:"dive"`
+}
+
+type BundleChoice struct {
+	SlotId              string             `json:"slot_id" validate:"required"`
+	FoodId              string             `json:"food_id" validate:"required"`
+	Modifiers           []SelectedModifier `json:"modifiers" validate:"dive"`
+	SpecialInstructions *string            `json:"special_instructions" validate:"omitempty,max=200"`
+}
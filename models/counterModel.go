@@ -0,0 +1,6 @@
+package models
+
+type Counter struct {
+	ID  string `bson:"_id" json:"id"`
+	Seq int64  `json:"seq"`
+}
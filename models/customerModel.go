@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Customer struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Name          *string            `json:"name" validate:"required,min=2,max=60"`
+	Phone         *string            `json:"phone" validate:"required_without=Email,omitempty,min=6,max=20"`
+	Email         *string            `json:"email" validate:"omitempty,email"`
+	Allergies     []string           `json:"allergies"`
+	Preferences   []string           `json:"preferences"`
+	LoyaltyPoints int                `json:"loyalty_points"`
+	LifetimeSpend float64            `json:"lifetime_spend"`
+	LastVisitAt   *time.Time         `json:"last_visit_at"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	Version       int64              `json:"version"`
+	CustomerId    string             `json:"customer_id"`
+	Notes         []Note             `json:"notes,omitempty" bson:"-"`
+}
+
+type LoyaltyTransaction struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	TransactionId string             `json:"transaction_id"`
+	CustomerId    string             `json:"customer_id"`
+	InvoiceId     string             `json:"invoice_id"`
+	Type          string             `json:"type"`
+	Points        int                `json:"points"`
+	CreatedAt     time.Time          `json:"created_at"`
+	Version       int64              `json:"version"`
+}
+
+const (
+	LoyaltyEarn   = "EARN"
+	LoyaltyRedeem = "REDEEM"
+	LoyaltyRefund = "REFUND"
+)
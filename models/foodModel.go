@@ -7,12 +7,87 @@ import (
 )
 
 type Food struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Name      *string            `json:"name" validate:"required,min=2,max=40"`
-	Price     *float64           `json:"price" validate:"required"`
-	FoodImage *string            `json:"food_image" validate:"required"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-	FoodId    string             `json:"food_id" validate:"required"`
-	MenuId    *string            `json:"menu_id" validate:"required"`
+	ID             primitive.ObjectID     `bson:"_id"`
+	Name           *string                `json:"name" validate:"required,min=2,max=40"`
+	Description    *string                `json:"description" validate:"omitempty,max=500"`
+	Translations   map[string]Translation `json:"translations" validate:"dive"`
+	Language       string                 `json:"language,omitempty" bson:"-"`
+	Price          *float64               `json:"price" validate:"required"`
+	FoodImage      *string                `json:"food_image"`
+	ImageId        *string                `json:"image_id" validate:"required_without=FoodImage"`
+	SizePrices     []SizePrice            `json:"size_prices" validate:"dive"`
+	ModifierGroups []ModifierGroup        `json:"modifier_groups" validate:"dive"`
+	Allergens      []string               `json:"allergens" validate:"dive,eq=CELERY|eq=GLUTEN|eq=CRUSTACEANS|eq=EGGS|eq=FISH|eq=LUPIN|eq=MILK|eq=MOLLUSCS|eq=MUSTARD|eq=TREE_NUTS|eq=PEANUTS|eq=SESAME|eq=SOYA|eq=SULPHITES"`
+	DietaryTags    []string               `json:"dietary_tags" validate:"dive,eq=VEGAN|eq=VEGETARIAN|eq=HALAL|eq=KOSHER|eq=GLUTEN_FREE|eq=DAIRY_FREE|eq=NUT_FREE"`
+	Nutrition      *Nutrition             `json:"nutrition"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+	Version        int64                  `json:"version"`
+	DeletedAt      *time.Time             `json:"deleted_at"`
+	DeletedBy      *string                `json:"deleted_by"`
+	FoodId         string                 `json:"food_id" validate:"required"`
+	MenuId         *string                `json:"menu_id" validate:"required"`
+	Notes          []Note                 `json:"notes,omitempty" bson:"-"`
 }
+
+type SizePrice struct {
+	Size  *string  `json:"size" validate:"required,eq=S|eq=M|eq=L"`
+	Price *float64 `json:"price" validate:"required,min=0"`
+}
+
+type ModifierGroup struct {
+	ModifierGroupId string           `json:"modifier_group_id"`
+	Name            *string          `json:"name" validate:"required,min=2,max=40"`
+	Required        bool             `json:"required"`
+	MinChoices      int              `json:"min_choices" validate:"min=0"`
+	MaxChoices      int              `json:"max_choices" validate:"min=0"`
+	Options         []ModifierOption `json:"options" validate:"required,min=1,dive"`
+}
+
+type ModifierOption struct {
+	OptionId   string   `json:"option_id"`
+	Name       *string  `json:"name" validate:"required,min=2,max=40"`
+	PriceDelta *float64 `json:"price_delta"`
+	Allergens  []string `json:"allergens" validate:"dive,eq=CELERY|eq=GLUTEN|eq=CRUSTACEANS|eq=EGGS|eq=FISH|eq=LUPIN|eq=MILK|eq=MOLLUSCS|eq=MUSTARD|eq=TREE_NUTS|eq=PEANUTS|eq=SESAME|eq=SOYA|eq=SULPHITES"`
+}
+
+// Nutrition facts are per serving, energy in kcal and everything else in
+// grams. Facts nobody measured stay empty rather than zero.
+type Nutrition struct {
+	ServingSize   *string  `json:"serving_size" validate:"omitempty,max=40"`
+	Calories      *float64 `json:"calories" validate:"omitempty,min=0"`
+	Protein       *float64 `json:"protein" validate:"omitempty,min=0"`
+	Carbohydrates *float64 `json:"carbohydrates" validate:"omitempty,min=0"`
+	Sugar         *float64 `json:"sugar" validate:"omitempty,min=0"`
+	Fat           *float64 `json:"fat" validate:"omitempty,min=0"`
+	SaturatedFat  *float64 `json:"saturated_fat" validate:"omitempty,min=0"`
+	Salt          *float64 `json:"salt" validate:"omitempty,min=0"`
+}
+
+// The fourteen allergens EU food law requires restaurants to declare.
+const (
+	AllergenCelery      = "CELERY"
+	AllergenGluten      = "GLUTEN"
+	AllergenCrustaceans = "CRUSTACEANS"
+	AllergenEggs        = "EGGS"
+	AllergenFish        = "FISH"
+	AllergenLupin       = "LUPIN"
+	AllergenMilk        = "MILK"
+	AllergenMolluscs    = "MOLLUSCS"
+	AllergenMustard     = "MUSTARD"
+	AllergenTreeNuts    = "TREE_NUTS"
+	AllergenPeanuts     = "PEANUTS"
+	AllergenSesame      = "SESAME"
+	AllergenSoya        = "SOYA"
+	AllergenSulphites   = "SULPHITES"
+)
+
+const (
+	DietaryVegan      = "VEGAN"
+	DietaryVegetarian = "VEGETARIAN"
+	DietaryHalal      = "HALAL"
+	DietaryKosher     = "KOSHER"
+	DietaryGlutenFree = "GLUTEN_FREE"
+	DietaryDairyFree  = "DAIRY_FREE"
+	DietaryNutFree    = "NUT_FREE"
+)
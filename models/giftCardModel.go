@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type GiftCard struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Code           string             `json:"code"`
+	InitialBalance *float64           `json:"initial_balance" validate:"required,gt=0"`
+	Balance        float64            `json:"balance"`
+	ExpiresAt      *time.Time         `json:"expires_at"`
+	Status         string             `json:"status"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	Version        int64              `json:"version"`
+	GiftCardId     string             `json:"gift_card_id"`
+}
+
+type GiftCardTransaction struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	TransactionId string             `json:"transaction_id"`
+	GiftCardId    string             `json:"gift_card_id"`
+	InvoiceId     string             `json:"invoice_id,omitempty"`
+	Type          string             `json:"type"`
+	Amount        float64            `json:"amount"`
+	BalanceAfter  float64            `json:"balance_after"`
+	CreatedAt     time.Time          `json:"created_at"`
+	Version       int64              `json:"version"`
+}
+
+const (
+	GiftCardActive = "ACTIVE"
+
+	GiftCardIssue  = "ISSUE"
+	GiftCardReload = "RELOAD"
+	GiftCardRedeem = "REDEEM"
+	GiftCardRefund = "REFUND"
+)
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Image describes an uploaded image. The bytes live in the blob store under
+// their content hash, the image and its thumbnail each under their own.
+type Image struct {
+	ID                   primitive.ObjectID `bson:"_id"`
+	ContentType          string             `json:"content_type"`
+	Size                 int64              `json:"size"`
+	Width                int                `json:"width"`
+	Height               int                `json:"height"`
+	Hash                 string             `json:"hash"`
+	ThumbnailHash        string             `json:"thumbnail_hash"`
+	ThumbnailContentType string             `json:"thumbnail_content_type"`
+	ThumbnailWidth       int                `json:"thumbnail_width"`
+	ThumbnailHeight      int                `json:"thumbnail_height"`
+	UploadedBy           *string            `json:"uploaded_by"`
+	CreatedAt            time.Time          `json:"created_at"`
+	Version              int64              `json:"version"`
+	ImageId              string             `json:"image_id"`
+}
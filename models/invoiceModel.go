@@ -9,10 +9,29 @@ import (
 type Invoice struct {
 	ID             primitive.ObjectID `bson:"_id"`
 	InvoiceId      string             `json:"invoice_id"`
+	InvoiceNumber  string             `json:"invoice_number"`
+	RestaurantId   string             `json:"restaurant_id"`
+	FiscalYear     int                `json:"fiscal_year"`
 	OrderId        string             `json:"order_id"`
-	PaymentMethod  *string            `json:"payment_method" validate:"eq=CASH|eq=CARD|eq="`
+	SeatNumbers    []int              `json:"seat_numbers" validate:"dive,min=0"`
+	PaymentMethod  *string            `json:"payment_method" validate:"eq=CASH|eq=CARD|eq=LOYALTY|eq=GIFT_CARD|eq=MIXED|eq="`
 	PaymentStatus  *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID"`
+	Payments       []Payment          `json:"payments"`
+	AmountPaid     float64            `json:"amount_paid"`
+	LoyaltyAwarded bool               `json:"loyalty_awarded"`
 	PaymentDueDate time.Time          `json:"payment_due_date"`
 	CreatedAt      time.Time          `json:"created_at"`
 	UpdatedAt      time.Time          `json:"updated_at"`
+	Version        int64              `json:"version"`
+	DeletedAt      *time.Time         `json:"deleted_at"`
+	DeletedBy      *string            `json:"deleted_by"`
+}
+
+type Payment struct {
+	PaymentId    string    `json:"payment_id"`
+	Method       string    `json:"method" validate:"required,eq=CASH|eq=CARD|eq=LOYALTY|eq=GIFT_CARD"`
+	Amount       float64   `json:"amount" validate:"min=0"`
+	Points       int       `json:"points,omitempty" validate:"min=0"`
+	GiftCardCode string    `json:"gift_card_code,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
 }
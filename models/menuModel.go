@@ -7,12 +7,18 @@ import (
 )
 
 type Menu struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Name      string             `json:"name" validate:"required"`
-	Category  string             `json:"category" validate:"required"`
-	StartDate *time.Time         `json:"start_date"`
-	EndDate   *time.Time         `json:"end_date"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-	MenuId    string             `json:"menu_id"`
+	ID           primitive.ObjectID     `bson:"_id"`
+	Name         string                 `json:"name" validate:"required"`
+	Category     string                 `json:"category" validate:"required"`
+	Description  *string                `json:"description" validate:"omitempty,max=500"`
+	Translations map[string]Translation `json:"translations" validate:"dive"`
+	Language     string                 `json:"language,omitempty" bson:"-"`
+	StartDate    *time.Time             `json:"start_date"`
+	EndDate      *time.Time             `json:"end_date"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	Version      int64                  `json:"version"`
+	DeletedAt    *time.Time             `json:"deleted_at"`
+	DeletedBy    *string                `json:"deleted_by"`
+	MenuId       string                 `json:"menu_id"`
 }
@@ -7,10 +7,25 @@ import (
 )
 
 type Note struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Title     string             `json:"title"`
-	Text      string             `json:"text"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-	NoteId    string             `json:"note_id"`
+	ID         primitive.ObjectID `bson:"_id"`
+	Title      string             `json:"title" validate:"required,max=100"`
+	Text       string             `json:"text" validate:"required,max=2000"`
+	EntityType string             `json:"entity_type" validate:"required,eq=ORDER|eq=TABLE|eq=RESERVATION|eq=CUSTOMER|eq=FOOD"`
+	EntityId   string             `json:"entity_id" validate:"required"`
+	Category   string             `json:"category" validate:"omitempty,eq=GENERAL|eq=ALLERGY|eq=VIP|eq=HANDOVER"`
+	Pinned     *bool              `json:"pinned"`
+	AuthorId   *string            `json:"author_id"`
+	UpdatedBy  *string            `json:"updated_by"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	Version    int64              `json:"version"`
+	NoteId     string             `json:"note_id"`
 }
+
+const (
+	NoteEntityOrder       = "ORDER"
+	NoteEntityTable       = "TABLE"
+	NoteEntityReservation = "RESERVATION"
+	NoteEntityCustomer    = "CUSTOMER"
+	NoteEntityFood        = "FOOD"
+)
@@ -7,12 +7,64 @@ import (
 )
 
 type OrderItem struct {
-	ID          primitive.ObjectID `bson:"_id"`
-	Quantity    *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	UnitPrice   *float64           `json:"unit_price" validate:"required"`
-	CreatedAt   time.Time          `json:"created_at"`
-	UpdatedAt   time.Time          `json:"updated_at"`
-	FoodId      *string            `json:"food_id" validate:"required"`
-	OrderItemId string             `json:"order_item_id"`
-	OrderId     string             `json:"order_id" validate:"required"`
+	ID                  primitive.ObjectID `bson:"_id"`
+	PortionSize         *string            `json:"portion_size" validate:"omitempty,eq=S|eq=M|eq=L"`
+	Quantity            *int               `json:"quantity" validate:"required,min=1"`
+	UnitPrice           *float64           `json:"unit_price"`
+	Modifiers           []SelectedModifier `json:"modifiers" validate:"dive"`
+	ModifiersPrice      float64            `json:"modifiers_price"`
+	SpecialInstructions *string            `json:"special_instructions" validate:"omitempty,max=200"`
+	Course              *string            `json:"course" validate:"omitempty,eq=STARTER|eq=MAIN|eq=DESSERT"`
+	SeatNumber          *int               `json:"seat_number" validate:"omitempty,min=1"`
+	FireStatus          string             `json:"fire_status"`
+	FiredAt             *time.Time         `json:"fired_at"`
+	ApprovalStatus      string             `json:"approval_status"`
+	ReviewedBy          *string            `json:"reviewed_by"`
+	ReviewedAt          *time.Time         `json:"reviewed_at"`
+	CreatedAt           time.Time          `json:"created_at"`
+	UpdatedAt           time.Time          `json:"updated_at"`
+	Version             int64              `json:"version"`
+	FoodId              *string            `json:"food_id" validate:"required"`
+	ItemType            string             `json:"item_type"`
+	BundleId            *string            `json:"bundle_id"`
+	BundleSlotId        string             `json:"bundle_slot_id"`
+	ParentOrderItemId   *string            `json:"parent_order_item_id"`
+	OrderItemId         string             `json:"order_item_id"`
+	OrderId             string             `json:"order_id" validate:"required"`
+}
+
+// Ordering a bundle stores one BUNDLE item that carries the bundle price and
+// one BUNDLE_COMPONENT item per chosen food for the kitchen, priced at zero.
+const (
+	OrderItemTypeBundle          = "BUNDLE"
+	OrderItemTypeBundleComponent = "BUNDLE_COMPONENT"
+)
+
+// Items of later courses are HELD until a waiter fires their course, only
+// FIRED items show up in the kitchen queue.
+const (
+	CourseStarter = "STARTER"
+	CourseMain    = "MAIN"
+	CourseDessert = "DESSERT"
+
+	FireStatusHeld  = "HELD"
+	FireStatusFired = "FIRED"
+)
+
+// Items guests order from the table's QR code wait as PENDING until a waiter
+// approves them. Items entered by staff, and items stored before guest
+// ordering, have no approval status and count as approved.
+const (
+	ApprovalPending  = "PENDING"
+	ApprovalApproved = "APPROVED"
+	ApprovalRejected = "REJECTED"
+)
+
+type SelectedModifier struct {
+	ModifierGroupId string   `json:"modifier_group_id" validate:"required"`
+	OptionId        string   `json:"option_id" validate:"required"`
+	GroupName       string   `json:"group_name"`
+	Name            string   `json:"name"`
+	PriceDelta      float64  `json:"price_delta"`
+	Allergens       []string `json:"allergens"`
 }
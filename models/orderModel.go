@@ -7,10 +7,43 @@ import (
 )
 
 type Order struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	OrderDate time.Time          `json:"order_date" validate:"required"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-	OrderId   string             `json:"order_id"`
-	TableId   *string            `json:"table_id" validate:"required"`
+	ID               primitive.ObjectID `bson:"_id"`
+	OrderDate        time.Time          `json:"order_date" validate:"required"`
+	CreatedAt        time.Time          `json:"created_at"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+	Version          int64              `json:"version"`
+	DeletedAt        *time.Time         `json:"deleted_at"`
+	DeletedBy        *string            `json:"deleted_by"`
+	OrderId          string             `json:"order_id"`
+	OrderType        string             `json:"order_type" validate:"required,eq=DINE_IN|eq=TAKEAWAY|eq=DELIVERY"`
+	TableId          *string            `json:"table_id" validate:"required_if=OrderType DINE_IN"`
+	CustomerId       *string            `json:"customer_id"`
+	PickupTime       *time.Time         `json:"pickup_time" validate:"required_if=OrderType TAKEAWAY"`
+	Contact          *OrderContact      `json:"contact" validate:"required_unless=OrderType DINE_IN"`
+	DeliveryAddress  *Address           `json:"delivery_address" validate:"required_if=OrderType DELIVERY"`
+	DeliveryFee      *float64           `json:"delivery_fee" validate:"omitempty,min=0"`
+	DriverId         *string            `json:"driver_id"`
+	DriverAssignedAt *time.Time         `json:"driver_assigned_at"`
+	BilledAt         *time.Time         `json:"billed_at"`
+	Notes            []Note             `json:"notes,omitempty" bson:"-"`
 }
+
+type OrderContact struct {
+	Name  *string `json:"name" validate:"required,min=2,max=60"`
+	Phone *string `json:"phone" validate:"required,min=6,max=20"`
+}
+
+type Address struct {
+	Line1        *string `json:"line1" validate:"required,max=100"`
+	Line2        *string `json:"line2" validate:"omitempty,max=100"`
+	City         *string `json:"city" validate:"required,max=60"`
+	PostalCode   *string `json:"postal_code" validate:"required,max=20"`
+	Instructions *string `json:"instructions" validate:"omitempty,max=200"`
+}
+
+// Orders stored before order types existed were all eaten at a table.
+const (
+	OrderTypeDineIn   = "DINE_IN"
+	OrderTypeTakeaway = "TAKEAWAY"
+	OrderTypeDelivery = "DELIVERY"
+)
@@ -12,5 +12,10 @@ type Table struct {
 	TableNumber    *int               `json:"table_number" validate:"required"`
 	CreatedAt      time.Time          `json:"created_at"`
 	UpdatedAt      time.Time          `json:"updated_at"`
+	Version        int64              `json:"version"`
+	DeletedAt      *time.Time         `json:"deleted_at"`
+	DeletedBy      *string            `json:"deleted_by"`
 	TableId        string             `json:"table_id"`
+	LinkNonce      string             `json:"link_nonce"`
+	Notes          []Note             `json:"notes,omitempty" bson:"-"`
 }
@@ -0,0 +1,10 @@
+package models
+
+// Translation holds a menu's or food's name and description in one language.
+// Translations are keyed by lower case language tag, like "de" or "pt-br".
+// Whatever is missing falls back to the next language the guest accepts and
+// finally to the untranslated fields in the restaurant's default language.
+type Translation struct {
+	Name        *string `json:"name" validate:"omitempty,min=2,max=60"`
+	Description *string `json:"description" validate:"omitempty,max=500"`
+}
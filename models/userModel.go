@@ -17,5 +17,6 @@ type User struct {
 	RefreshToken *string            `json:"refresh_token"`
 	CreatedAt    time.Time          `json:"created_at"`
 	UpdatedAt    time.Time          `json:"updated_at"`
+	Version      int64              `json:"version"`
 	UserId       string             `json:"user_id"`
 }
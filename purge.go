@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+)
+
+// purgeDeleted removes the records soft deleted longer than DELETED_RETENTION
+// ago (30 days by default), every PURGE_INTERVAL (an hour by default), until
+// ctx is done.
+func purgeDeleted(ctx context.Context, h *controllers.Handler) {
+	retention := durationFromEnv("DELETED_RETENTION", 30*24*time.Hour)
+	ticker := time.NewTicker(durationFromEnv("PURGE_INTERVAL", time.Hour))
+	defer ticker.Stop()
+
+	for {
+		purged, err := h.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("cannot purge the deleted records: %v", err)
+		}
+		for collection, count := range purged {
+			log.Printf("purged %d deleted %s", count, collection)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
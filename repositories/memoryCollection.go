@@ -0,0 +1,812 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryCollection keeps documents in memory and answers the filters,
+// updates and options the handlers send to Mongo. Aggregations only run
+// $match, $sort, $skip, $limit, $project, $count, $facet, $unwind and $lookup
+// by localField and foreignField, update pipelines only $set and $unset, and
+// $text or $expr queries fail, so handlers built on those still need a real
+// database.
+type MemoryCollection struct {
+	mu     sync.Mutex
+	docs   []bson.D
+	unique []string
+	// collections are the ones a $lookup can read from, by name
+	collections map[string]*MemoryCollection
+}
+
+// NewMemoryCollection rejects documents repeating a value of the unique
+// fields, as a unique index would. Documents without the field never clash.
+func NewMemoryCollection(unique ...string) *MemoryCollection {
+	return &MemoryCollection{unique: unique}
+}
+
+// memoryUniqueFields mirror the unique indexes of the migrations.
+var memoryUniqueFields = map[string][]string{
+	bundleCollection:              {"bundle_id"},
+	customerCollection:            {"customer_id"},
+	foodCollection:                {"food_id"},
+	giftCardCollection:            {"gift_card_id", "code"},
+	giftCardTransactionCollection: {"transaction_id"},
+	imageCollection:               {"image_id"},
+	invoiceCollection:             {"invoice_id", "invoice_number"},
+	loyaltyTransactionCollection:  {"transaction_id"},
+	menuCollection:                {"menu_id"},
+	noteCollection:                {"note_id"},
+	orderCollection:               {"order_id"},
+	orderItemCollection:           {"order_item_id"},
+	tableCollection:               {"table_id"},
+	userCollection:                {"user_id", "email"},
+}
+
+// NewMemoryRepos keeps every aggregate in memory. Transactions just run their
+// function, so handlers take the path for deployments without them.
+func NewMemoryRepos() Repos {
+	collections := map[string]*MemoryCollection{}
+	open := func(name string) Collection {
+		collection := NewMemoryCollection(memoryUniqueFields[name]...)
+		collection.collections = collections
+		collections[name] = collection
+		return collection
+	}
+	return newRepos(open, memoryTransactor{})
+}
+
+type memoryTransactor struct{}
+
+func (memoryTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (memoryTransactor) SupportsTransactions(ctx context.Context) bool {
+	return false
+}
+
+// filtered returns the indexes of the documents matching filter. The caller
+// holds the lock.
+func (m *MemoryCollection) filtered(filter interface{}) ([]int, error) {
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	indexes := []int{}
+	for i, doc := range m.docs {
+		matched, err := matches(doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+// find returns copies of the matching documents, sorted, skipped and limited.
+func (m *MemoryCollection) find(filter interface{}, sortSpec interface{}, skip *int64, limit *int64) ([]bson.D, error) {
+	indexes, err := m.filtered(filter)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]bson.D, 0, len(indexes))
+	for _, i := range indexes {
+		docs = append(docs, clone(m.docs[i]).(bson.D))
+	}
+	return window(docs, sortSpec, skip, limit)
+}
+
+func window(docs []bson.D, sortSpec interface{}, skip *int64, limit *int64) ([]bson.D, error) {
+	if sortSpec != nil {
+		spec, err := toDocument(sortSpec)
+		if err != nil {
+			return nil, err
+		}
+		sortDocuments(docs, spec)
+	}
+	if skip != nil && *skip > 0 {
+		if *skip >= int64(len(docs)) {
+			return []bson.D{}, nil
+		}
+		docs = docs[*skip:]
+	}
+	if limit != nil && *limit != 0 {
+		n := *limit
+		if n < 0 {
+			n = -n
+		}
+		if n < int64(len(docs)) {
+			docs = docs[:n]
+		}
+	}
+	return docs, nil
+}
+
+func projectAll(docs []bson.D, projection interface{}) ([]bson.D, error) {
+	if projection == nil {
+		return docs, nil
+	}
+	spec, err := toDocument(projection)
+	if err != nil {
+		return nil, err
+	}
+	for i, doc := range docs {
+		if docs[i], err = project(doc, spec); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// memoryCursor walks documents found already, decoding them the way the
+// driver decodes what the server sends.
+type memoryCursor struct {
+	docs []bson.D
+	// next is one past the document Decode reads
+	next int
+}
+
+func cursor(docs []bson.D) (Cursor, error) {
+	return &memoryCursor{docs: docs}, nil
+}
+
+func (c *memoryCursor) Next(ctx context.Context) bool {
+	if c.next >= len(c.docs) {
+		return false
+	}
+	c.next++
+	return true
+}
+
+func (c *memoryCursor) Decode(val interface{}) error {
+	if c.next == 0 {
+		return errors.New("Decode called before Next")
+	}
+	return decode(c.docs[c.next-1], val)
+}
+
+// All decodes the documents left into results, a pointer to a slice, and
+// closes the cursor.
+func (c *memoryCursor) All(ctx context.Context, results interface{}) error {
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results must be a pointer to a slice, not %T", results)
+	}
+	all := reflect.MakeSlice(slice.Elem().Type(), 0, len(c.docs)-c.next)
+	for ; c.next < len(c.docs); c.next++ {
+		element := reflect.New(all.Type().Elem())
+		if err := decode(c.docs[c.next], element.Interface()); err != nil {
+			return err
+		}
+		all = reflect.Append(all, element.Elem())
+	}
+	slice.Elem().Set(all)
+	return nil
+}
+
+func (c *memoryCursor) Err() error {
+	return nil
+}
+
+func (c *memoryCursor) Close(ctx context.Context) error {
+	c.next = len(c.docs)
+	return nil
+}
+
+type memorySingleResult struct {
+	doc bson.D
+	err error
+}
+
+func singleResult(doc bson.D, err error) SingleResult {
+	return memorySingleResult{doc, err}
+}
+
+func (r memorySingleResult) Decode(v interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return decode(r.doc, v)
+}
+
+func (r memorySingleResult) Err() error {
+	return r.err
+}
+
+func duplicateKey(field string, value interface{}) error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    11000,
+		Message: fmt.Sprintf("E11000 duplicate key error dup key: { %s: %v }", field, value),
+	}}}
+}
+
+// checkUnique makes sure doc, about to be stored at index self or appended
+// when self is -1, repeats no unique value of another document.
+func (m *MemoryCollection) checkUnique(doc bson.D, self int) error {
+	for _, field := range append([]string{"_id"}, m.unique...) {
+		value, ok := getPath(doc, strings.Split(field, "."))
+		if !ok || typeOrder(value) == 1 {
+			continue
+		}
+		for i, existing := range m.docs {
+			if i == self {
+				continue
+			}
+			if other, ok := getPath(existing, strings.Split(field, ".")); ok && containsValue([]interface{}{other}, value) {
+				return duplicateKey(field, value)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (Cursor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	opt := options.MergeFindOptions(opts...)
+	docs, err := m.find(filter, opt.Sort, opt.Skip, opt.Limit)
+	if err == nil {
+		docs, err = projectAll(docs, opt.Projection)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cursor(docs)
+}
+
+func (m *MemoryCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) SingleResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	opt := options.MergeFindOneOptions(opts...)
+	limit := int64(1)
+	docs, err := m.find(filter, opt.Sort, opt.Skip, &limit)
+	if err == nil {
+		docs, err = projectAll(docs, opt.Projection)
+	}
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	if len(docs) == 0 {
+		return singleResult(nil, mongo.ErrNoDocuments)
+	}
+	return singleResult(docs[0], nil)
+}
+
+func (m *MemoryCollection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	opt := options.MergeCountOptions(opts...)
+	docs, err := m.find(filter, nil, opt.Skip, opt.Limit)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+
+func (m *MemoryCollection) Distinct(ctx context.Context, fieldName string, filter interface{}, opts ...*options.DistinctOptions) ([]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	docs, err := m.find(filter, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	values := []interface{}{}
+	for _, doc := range docs {
+		for _, value := range lookup(doc, strings.Split(fieldName, ".")) {
+			candidates := []interface{}{value}
+			if array, ok := value.(bson.A); ok {
+				candidates = array
+			}
+			for _, candidate := range candidates {
+				if !containsValue(values, candidate) {
+					values = append(values, candidate)
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+func containsValue(values []interface{}, want interface{}) bool {
+	for _, value := range values {
+		if typeOrder(value) == typeOrder(want) && compare(value, want) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// insert stores a copy of document and returns its _id, generating one when
+// the document has none.
+func (m *MemoryCollection) insert(document interface{}) (interface{}, error) {
+	doc, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := lookupKey(doc, "_id")
+	if !ok {
+		id = primitive.NewObjectID()
+		doc = append(bson.D{{Key: "_id", Value: id}}, doc...)
+	}
+	if err := m.checkUnique(doc, -1); err != nil {
+		return nil, err
+	}
+	m.docs = append(m.docs, doc)
+	return id, nil
+}
+
+func (m *MemoryCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := m.insert(document)
+	if err != nil {
+		return nil, err
+	}
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+// InsertMany stops at the first failing document, like an ordered insert.
+func (m *MemoryCollection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &mongo.InsertManyResult{}
+	for _, document := range documents {
+		id, err := m.insert(document)
+		if err != nil {
+			return result, err
+		}
+		result.InsertedIDs = append(result.InsertedIDs, id)
+	}
+	return result, nil
+}
+
+// update changes the first or all matching documents and returns the
+// indexes it touched.
+func (m *MemoryCollection) update(filter interface{}, update interface{}, upsert *bool, many bool) (*mongo.UpdateResult, []int, error) {
+	apply, err := updater(update)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexes, err := m.filtered(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !many && len(indexes) > 1 {
+		indexes = indexes[:1]
+	}
+
+	result := &mongo.UpdateResult{MatchedCount: int64(len(indexes))}
+	for _, i := range indexes {
+		doc := clone(m.docs[i]).(bson.D)
+		if err := apply(&doc, false); err != nil {
+			return nil, nil, err
+		}
+		if err := m.checkUnique(doc, i); err != nil {
+			return nil, nil, err
+		}
+		if compare(doc, m.docs[i]) != 0 {
+			result.ModifiedCount++
+		}
+		m.docs[i] = doc
+	}
+
+	if len(indexes) == 0 && upsert != nil && *upsert {
+		query, err := toDocument(filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		doc, err := upsertDocument(query)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := apply(&doc, true); err != nil {
+			return nil, nil, err
+		}
+		id, err := m.insert(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		result.UpsertedCount = 1
+		result.UpsertedID = id
+		indexes = []int{len(m.docs) - 1}
+	}
+
+	return result, indexes, nil
+}
+
+func (m *MemoryCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result, _, err := m.update(filter, update, options.MergeUpdateOptions(opts...).Upsert, false)
+	return result, err
+}
+
+func (m *MemoryCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result, _, err := m.update(filter, update, options.MergeUpdateOptions(opts...).Upsert, true)
+	return result, err
+}
+
+// ReplaceOne swaps the first matching document for replacement, keeping its
+// _id when replacement has none.
+func (m *MemoryCollection) ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc, err := toDocument(replacement)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := m.filtered(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(indexes) == 0 {
+		result := &mongo.UpdateResult{}
+		if upsert := options.MergeReplaceOptions(opts...).Upsert; upsert != nil && *upsert {
+			id, err := m.insert(doc)
+			if err != nil {
+				return nil, err
+			}
+			result.UpsertedCount = 1
+			result.UpsertedID = id
+		}
+		return result, nil
+	}
+
+	i := indexes[0]
+	id, _ := lookupKey(m.docs[i], "_id")
+	if replacementId, ok := lookupKey(doc, "_id"); !ok {
+		doc = append(bson.D{{Key: "_id", Value: id}}, doc...)
+	} else if compare(replacementId, id) != 0 {
+		return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 66, Message: "the replacement would modify the immutable field '_id'"}}}
+	}
+	if err := m.checkUnique(doc, i); err != nil {
+		return nil, err
+	}
+
+	result := &mongo.UpdateResult{MatchedCount: 1}
+	if compare(doc, m.docs[i]) != 0 {
+		result.ModifiedCount = 1
+	}
+	m.docs[i] = doc
+	return result, nil
+}
+
+func (m *MemoryCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) SingleResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	opt := options.MergeFindOneAndUpdateOptions(opts...)
+	if opt.Sort != nil {
+		// update the first document in sort order
+		docs, err := m.find(filter, opt.Sort, nil, nil)
+		if err != nil {
+			return singleResult(nil, err)
+		}
+		if len(docs) > 0 {
+			id, _ := lookupKey(docs[0], "_id")
+			filter = bson.D{{Key: "_id", Value: id}}
+		}
+	}
+
+	var before bson.D
+	if indexes, err := m.filtered(filter); err != nil {
+		return singleResult(nil, err)
+	} else if len(indexes) > 0 {
+		before = clone(m.docs[indexes[0]]).(bson.D)
+	}
+
+	_, indexes, err := m.update(filter, update, opt.Upsert, false)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	if len(indexes) == 0 {
+		return singleResult(nil, mongo.ErrNoDocuments)
+	}
+
+	doc := before
+	if opt.ReturnDocument != nil && *opt.ReturnDocument == options.After {
+		doc = clone(m.docs[indexes[0]]).(bson.D)
+	}
+	if doc == nil {
+		// the upsert inserted it, there was nothing before
+		return singleResult(nil, mongo.ErrNoDocuments)
+	}
+	docs, err := projectAll([]bson.D{doc}, opt.Projection)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	return singleResult(docs[0], nil)
+}
+
+func (m *MemoryCollection) delete(filter interface{}, many bool) (*mongo.DeleteResult, error) {
+	indexes, err := m.filtered(filter)
+	if err != nil {
+		return nil, err
+	}
+	if !many && len(indexes) > 1 {
+		indexes = indexes[:1]
+	}
+
+	deleted := map[int]bool{}
+	for _, i := range indexes {
+		deleted[i] = true
+	}
+	kept := m.docs[:0]
+	for i, doc := range m.docs {
+		if !deleted[i] {
+			kept = append(kept, doc)
+		}
+	}
+	m.docs = kept
+	return &mongo.DeleteResult{DeletedCount: int64(len(indexes))}, nil
+}
+
+func (m *MemoryCollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.delete(filter, false)
+}
+
+func (m *MemoryCollection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.delete(filter, true)
+}
+
+// Aggregate runs the stages that need no expression language. $lookup reads
+// the other collections of NewMemoryRepos by localField and foreignField.
+func (m *MemoryCollection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (Cursor, error) {
+	wrapped, err := toDocument(bson.D{{Key: "pipeline", Value: pipeline}})
+	if err != nil {
+		return nil, err
+	}
+	stages, ok := wrapped[0].Value.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("pipeline must be an array of stages")
+	}
+
+	m.mu.Lock()
+	docs := make([]bson.D, len(m.docs))
+	for i, doc := range m.docs {
+		docs[i] = clone(doc).(bson.D)
+	}
+	m.mu.Unlock()
+
+	if docs, err = aggregate(docs, stages, m.collections); err != nil {
+		return nil, err
+	}
+	return cursor(docs)
+}
+
+func aggregate(docs []bson.D, stages bson.A, collections map[string]*MemoryCollection) ([]bson.D, error) {
+	var err error
+	for _, s := range stages {
+		stage, ok := s.(bson.D)
+		if !ok || len(stage) != 1 {
+			return nil, fmt.Errorf("each stage must be a document with one field")
+		}
+		name, arg := stage[0].Key, stage[0].Value
+
+		switch name {
+		case "$match":
+			filter, _ := arg.(bson.D)
+			matched := []bson.D{}
+			for _, doc := range docs {
+				ok, err := matches(doc, filter)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					matched = append(matched, doc)
+				}
+			}
+			docs = matched
+		case "$sort":
+			docs, err = window(docs, arg, nil, nil)
+		case "$skip":
+			skip := int64(toFloat(arg))
+			docs, err = window(docs, nil, &skip, nil)
+		case "$limit":
+			limit := int64(toFloat(arg))
+			docs, err = window(docs, nil, nil, &limit)
+		case "$project":
+			docs, err = projectAll(docs, arg)
+		case "$count":
+			field, _ := arg.(string)
+			counted := []bson.D{}
+			if len(docs) > 0 {
+				counted = append(counted, bson.D{{Key: field, Value: int32(len(docs))}})
+			}
+			docs = counted
+		case "$facet":
+			docs, err = facet(docs, arg, collections)
+		case "$lookup":
+			docs, err = lookupStage(docs, arg, collections)
+		case "$unwind":
+			docs, err = unwind(docs, arg)
+		default:
+			return nil, unsupported("the " + name + " stage")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// facet runs each pipeline of the stage on its own copy of the documents and
+// gathers their results in one document.
+func facet(docs []bson.D, arg interface{}, collections map[string]*MemoryCollection) ([]bson.D, error) {
+	pipelines, ok := arg.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("$facet takes a document of pipelines")
+	}
+	result := bson.D{}
+	for _, pipeline := range pipelines {
+		stages, ok := pipeline.Value.(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("$facet takes a document of pipelines")
+		}
+		copies := make([]bson.D, len(docs))
+		for i, doc := range docs {
+			copies[i] = clone(doc).(bson.D)
+		}
+		out, err := aggregate(copies, stages, collections)
+		if err != nil {
+			return nil, err
+		}
+		values := bson.A{}
+		for _, doc := range out {
+			values = append(values, doc)
+		}
+		result = append(result, bson.E{Key: pipeline.Key, Value: values})
+	}
+	return []bson.D{result}, nil
+}
+
+// lookupStage joins the documents of another collection whose foreignField
+// holds a value of localField, running the pipeline on them when there is
+// one.
+func lookupStage(docs []bson.D, arg interface{}, collections map[string]*MemoryCollection) ([]bson.D, error) {
+	spec, _ := arg.(bson.D)
+	settings := map[string]interface{}{}
+	for _, e := range spec {
+		settings[e.Key] = e.Value
+	}
+	from, _ := settings["from"].(string)
+	localField, _ := settings["localField"].(string)
+	foreignField, _ := settings["foreignField"].(string)
+	as, _ := settings["as"].(string)
+	if localField == "" || foreignField == "" || as == "" {
+		return nil, unsupported("$lookup without localField, foreignField and as")
+	}
+	pipeline, _ := settings["pipeline"].(bson.A)
+
+	foreign := []bson.D{}
+	if collection, ok := collections[from]; ok {
+		collection.mu.Lock()
+		for _, doc := range collection.docs {
+			foreign = append(foreign, clone(doc).(bson.D))
+		}
+		collection.mu.Unlock()
+	}
+
+	for i, doc := range docs {
+		local := expand(lookup(doc, strings.Split(localField, ".")))
+		if len(local) == 0 {
+			local = []interface{}{nil}
+		}
+
+		joined := []bson.D{}
+		for _, other := range foreign {
+			values := expand(lookup(other, strings.Split(foreignField, ".")))
+			if len(values) == 0 {
+				values = []interface{}{nil}
+			}
+			if anyEqual(local, values) {
+				joined = append(joined, clone(other).(bson.D))
+			}
+		}
+		joined, err := aggregate(joined, pipeline, collections)
+		if err != nil {
+			return nil, err
+		}
+
+		array := bson.A{}
+		for _, other := range joined {
+			array = append(array, other)
+		}
+		if err := setPath(&docs[i], strings.Split(as, "."), array); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+func anyEqual(a []interface{}, b []interface{}) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if compare(x, y) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unwind repeats each document once per element of the array at path.
+func unwind(docs []bson.D, arg interface{}) ([]bson.D, error) {
+	path, preserve := "", false
+	switch spec := arg.(type) {
+	case string:
+		path = spec
+	case bson.D:
+		for _, e := range spec {
+			switch e.Key {
+			case "path":
+				path, _ = e.Value.(string)
+			case "preserveNullAndEmptyArrays":
+				preserve, _ = e.Value.(bool)
+			}
+		}
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("$unwind takes a $ path")
+	}
+	fields := strings.Split(path[1:], ".")
+
+	unwound := []bson.D{}
+	for _, doc := range docs {
+		values := lookup(doc, fields)
+		var elements bson.A
+		if len(values) == 1 {
+			if array, ok := values[0].(bson.A); ok {
+				elements = array
+				if len(array) == 0 {
+					unsetPath(&doc, fields)
+				}
+			} else if values[0] != nil {
+				elements = bson.A{values[0]}
+			}
+		}
+		if len(elements) == 0 {
+			if preserve {
+				unwound = append(unwound, doc)
+			}
+			continue
+		}
+		for _, element := range elements {
+			copied := clone(doc).(bson.D)
+			if err := setPath(&copied, fields, element); err != nil {
+				return nil, err
+			}
+			unwound = append(unwound, copied)
+		}
+	}
+	return unwound, nil
+}
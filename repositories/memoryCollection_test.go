@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type testDoc struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+func TestMemoryCollectionFind(t *testing.T) {
+	ctx := context.Background()
+	collection := NewMemoryCollection("name")
+
+	for _, doc := range []testDoc{{"soup", 4.5}, {"bread", 2}, {"cake", 6}} {
+		if _, err := collection.InsertOne(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := collection.InsertOne(ctx, testDoc{"soup", 5}); !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("repeating a unique name gave %v, want a duplicate key error", err)
+	}
+
+	filter := bson.D{{Key: "price", Value: bson.D{{Key: "$gt", Value: 3}}}}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "price", Value: -1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []testDoc{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || docs[0].Name != "cake" || docs[1].Name != "soup" {
+		t.Errorf("found %v, want cake then soup", docs)
+	}
+
+	err = collection.FindOne(ctx, bson.D{{Key: "name", Value: "pie"}}).Decode(&testDoc{})
+	if err != mongo.ErrNoDocuments {
+		t.Errorf("finding a missing document gave %v, want mongo.ErrNoDocuments", err)
+	}
+}
+
+func TestMemoryCollectionPipelineUpdate(t *testing.T) {
+	ctx := context.Background()
+	collection := NewMemoryCollection()
+
+	if _, err := collection.InsertOne(ctx, testDoc{"soup", 0.1}); err != nil {
+		t.Fatal(err)
+	}
+
+	price := bson.D{{Key: "$round", Value: bson.A{bson.D{{Key: "$add", Value: bson.A{"$price", 0.2}}}, 2}}}
+	version := bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$version", 0}}}, 1}}}
+	update := bson.A{bson.D{{Key: "$set", Value: bson.D{{Key: "price", Value: price}, {Key: "version", Value: version}}}}}
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	updated := bson.M{}
+	if err := collection.FindOneAndUpdate(ctx, bson.D{{Key: "name", Value: "soup"}}, update, opt).Decode(&updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated["price"] != 0.3 || updated["version"] != int32(1) {
+		t.Errorf("updated to price %v and version %v, want 0.3 and 1", updated["price"], updated["version"])
+	}
+}
@@ -0,0 +1,974 @@
+package repositories
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// toDocument encodes v the way the driver would send it, so filters and
+// stored documents use the same field names and value types.
+func toDocument(v interface{}) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	raw, err := bson.MarshalWithRegistry(database.Registry, v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.D{}
+	if err := bson.UnmarshalWithRegistry(database.Registry, raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decode reads doc into v the way the driver decodes what the server sends.
+func decode(doc bson.D, v interface{}) error {
+	raw, err := bson.MarshalWithRegistry(database.Registry, doc)
+	if err != nil {
+		return err
+	}
+	return bson.UnmarshalWithRegistry(database.Registry, raw, v)
+}
+
+func unsupported(what string) error {
+	return fmt.Errorf("memory repository does not support %s", what)
+}
+
+func lookupKey(doc bson.D, key string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// lookup collects the values at a dotted path. Arrays on the way are
+// searched element by element, as Mongo does.
+func lookup(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	switch v := value.(type) {
+	case bson.D:
+		next, ok := lookupKey(v, path[0])
+		if !ok {
+			return nil
+		}
+		return lookup(next, path[1:])
+	case bson.A:
+		if index, err := strconv.Atoi(path[0]); err == nil {
+			if index >= 0 && index < len(v) {
+				return lookup(v[index], path[1:])
+			}
+			return nil
+		}
+		values := []interface{}{}
+		for _, element := range v {
+			if _, ok := element.(bson.D); ok {
+				values = append(values, lookup(element, path)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// expand adds the elements of array values, a condition on a field holding
+// an array matches when it matches any element.
+func expand(values []interface{}) []interface{} {
+	expanded := []interface{}{}
+	for _, value := range values {
+		expanded = append(expanded, value)
+		if array, ok := value.(bson.A); ok {
+			expanded = append(expanded, array...)
+		}
+	}
+	return expanded
+}
+
+func typeOrder(value interface{}) int {
+	switch value.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int32, int64, float64, primitive.Decimal128:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.D:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	}
+	return 12
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case primitive.Decimal128:
+		f, _ := strconv.ParseFloat(v.String(), 64)
+		return f
+	}
+	return math.NaN()
+}
+
+// compare orders two values the way Mongo sorts them.
+func compare(a, b interface{}) int {
+	orderA, orderB := typeOrder(a), typeOrder(b)
+	if orderA != orderB {
+		return orderA - orderB
+	}
+
+	switch av := a.(type) {
+	case int32, int64, float64, primitive.Decimal128:
+		x, y := toFloat(av), toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(av, fmt.Sprint(b))
+	case bson.D:
+		bv := b.(bson.D)
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := strings.Compare(av[i].Key, bv[i].Key); c != 0 {
+				return c
+			}
+			if c := compare(av[i].Value, bv[i].Value); c != 0 {
+				return c
+			}
+		}
+		return len(av) - len(bv)
+	case bson.A:
+		bv := b.(bson.A)
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := compare(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return len(av) - len(bv)
+	case primitive.Binary:
+		return bytes.Compare(av.Data, b.(primitive.Binary).Data)
+	case primitive.ObjectID:
+		bv := b.(primitive.ObjectID)
+		return bytes.Compare(av[:], bv[:])
+	case bool:
+		bv := b.(bool)
+		switch {
+		case av == bv:
+			return 0
+		case !av:
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		bv := b.(primitive.DateTime)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case primitive.Timestamp:
+		return primitive.CompareTimestamp(av, b.(primitive.Timestamp))
+	}
+	return 0
+}
+
+func isOperatorDocument(value interface{}) (bson.D, bool) {
+	doc, ok := value.(bson.D)
+	if !ok || len(doc) == 0 || !strings.HasPrefix(doc[0].Key, "$") {
+		return nil, false
+	}
+	return doc, true
+}
+
+// matches reports whether doc satisfies filter.
+func matches(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		var ok bool
+		var err error
+
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			ok, err = matchLogical(doc, e.Key, e.Value)
+		case "$expr", "$text", "$where", "$jsonSchema":
+			return false, unsupported(e.Key)
+		default:
+			if strings.HasPrefix(e.Key, "$") {
+				return false, unsupported(e.Key)
+			}
+			values := lookup(doc, strings.Split(e.Key, "."))
+			if ops, isOps := isOperatorDocument(e.Value); isOps {
+				ok, err = matchOperators(values, ops)
+			} else {
+				ok = matchEqual(values, e.Value)
+			}
+		}
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchLogical(doc bson.D, op string, value interface{}) (bool, error) {
+	clauses, ok := value.(bson.A)
+	if !ok || len(clauses) == 0 {
+		return false, fmt.Errorf("%s needs a non-empty array", op)
+	}
+	for _, clause := range clauses {
+		filter, ok := clause.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("%s needs documents", op)
+		}
+		matched, err := matches(doc, filter)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case op == "$and" && !matched:
+			return false, nil
+		case op == "$or" && matched:
+			return true, nil
+		case op == "$nor" && matched:
+			return false, nil
+		}
+	}
+	return op != "$or", nil
+}
+
+func matchEqual(values []interface{}, want interface{}) bool {
+	if typeOrder(want) == 1 && len(values) == 0 {
+		return true
+	}
+	for _, value := range expand(values) {
+		if typeOrder(value) == typeOrder(want) && compare(value, want) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperators(values []interface{}, ops bson.D) (bool, error) {
+	for _, op := range ops {
+		var ok bool
+		var err error
+
+		switch op.Key {
+		case "$eq":
+			ok = matchEqual(values, op.Value)
+		case "$ne":
+			ok = !matchEqual(values, op.Value)
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = matchRange(values, op.Key, op.Value)
+		case "$in", "$nin":
+			list, isList := op.Value.(bson.A)
+			if !isList {
+				return false, fmt.Errorf("%s needs an array", op.Key)
+			}
+			for _, want := range list {
+				if matchEqual(values, want) {
+					ok = true
+					break
+				}
+			}
+			if op.Key == "$nin" {
+				ok = !ok
+			}
+		case "$exists":
+			ok = (len(values) > 0) == truthy(op.Value)
+		case "$all":
+			list, isList := op.Value.(bson.A)
+			if !isList {
+				return false, fmt.Errorf("$all needs an array")
+			}
+			ok = len(list) > 0
+			for _, want := range list {
+				if !matchEqual(values, want) {
+					ok = false
+					break
+				}
+			}
+		case "$size":
+			for _, value := range values {
+				if array, isArray := value.(bson.A); isArray && float64(len(array)) == toFloat(op.Value) {
+					ok = true
+				}
+			}
+		case "$elemMatch":
+			ok, err = matchElement(values, op.Value)
+		case "$not":
+			negated, isOps := isOperatorDocument(op.Value)
+			if !isOps {
+				return false, fmt.Errorf("$not needs an operator document")
+			}
+			ok, err = matchOperators(values, negated)
+			ok = !ok
+		case "$regex":
+			options, _ := lookupKey(ops, "$options")
+			ok, err = matchRegex(values, op.Value, options)
+		case "$options":
+			continue
+		default:
+			return false, unsupported(op.Key)
+		}
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchRange(values []interface{}, op string, bound interface{}) bool {
+	for _, value := range expand(values) {
+		if typeOrder(value) != typeOrder(bound) {
+			continue
+		}
+		c := compare(value, bound)
+		if (op == "$gt" && c > 0) || (op == "$gte" && c >= 0) || (op == "$lt" && c < 0) || (op == "$lte" && c <= 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchElement(values []interface{}, condition interface{}) (bool, error) {
+	for _, value := range values {
+		array, ok := value.(bson.A)
+		if !ok {
+			continue
+		}
+		for _, element := range array {
+			var matched bool
+			var err error
+			if ops, isOps := isOperatorDocument(condition); isOps {
+				matched, err = matchOperators([]interface{}{element}, ops)
+			} else if doc, isDoc := element.(bson.D); isDoc {
+				filter, _ := condition.(bson.D)
+				matched, err = matches(doc, filter)
+			}
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func matchRegex(values []interface{}, pattern interface{}, options interface{}) (bool, error) {
+	expression, flags := "", ""
+	switch p := pattern.(type) {
+	case string:
+		expression = p
+	case primitive.Regex:
+		expression, flags = p.Pattern, p.Options
+	default:
+		return false, fmt.Errorf("$regex needs a string")
+	}
+	if o, ok := options.(string); ok {
+		flags += o
+	}
+
+	prefix := ""
+	for _, flag := range flags {
+		if strings.ContainsRune("ims", flag) {
+			prefix += string(flag)
+		}
+	}
+	if prefix != "" {
+		expression = "(?" + prefix + ")" + expression
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return false, err
+	}
+
+	for _, value := range expand(values) {
+		if s, ok := value.(string); ok && re.MatchString(s) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int32, int64, float64:
+		return toFloat(v) != 0
+	}
+	return true
+}
+
+// setPath sets a dotted path, creating the documents on the way.
+func setPath(doc *bson.D, path []string, value interface{}) error {
+	for i, e := range *doc {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			(*doc)[i].Value = value
+			return nil
+		}
+		return setIn(&(*doc)[i].Value, path[1:], value)
+	}
+
+	if len(path) == 1 {
+		*doc = append(*doc, bson.E{Key: path[0], Value: value})
+		return nil
+	}
+	child := bson.D{}
+	if err := setPath(&child, path[1:], value); err != nil {
+		return err
+	}
+	*doc = append(*doc, bson.E{Key: path[0], Value: child})
+	return nil
+}
+
+func setIn(target *interface{}, path []string, value interface{}) error {
+	switch v := (*target).(type) {
+	case bson.D:
+		if err := setPath(&v, path, value); err != nil {
+			return err
+		}
+		*target = v
+		return nil
+	case bson.A:
+		index, err := strconv.Atoi(path[0])
+		if err != nil {
+			return unsupported("positional updates")
+		}
+		for len(v) <= index {
+			v = append(v, nil)
+		}
+		if len(path) == 1 {
+			v[index] = value
+		} else if err := setIn(&v[index], path[1:], value); err != nil {
+			return err
+		}
+		*target = v
+		return nil
+	case nil:
+		child := bson.D{}
+		if err := setPath(&child, path, value); err != nil {
+			return err
+		}
+		*target = child
+		return nil
+	}
+	return fmt.Errorf("cannot create field %s in a %T", path[0], *target)
+}
+
+func unsetPath(doc *bson.D, path []string) {
+	for i, e := range *doc {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			*doc = append((*doc)[:i], (*doc)[i+1:]...)
+			return
+		}
+		if child, ok := e.Value.(bson.D); ok {
+			unsetPath(&child, path[1:])
+			(*doc)[i].Value = child
+		}
+		return
+	}
+}
+
+func getPath(doc bson.D, path []string) (interface{}, bool) {
+	var value interface{} = doc
+	for _, key := range path {
+		switch v := value.(type) {
+		case bson.D:
+			next, ok := lookupKey(v, key)
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case bson.A:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func addNumbers(a, b interface{}) (interface{}, error) {
+	if typeOrder(a) != 2 || typeOrder(b) != 2 {
+		return nil, fmt.Errorf("cannot $inc a %T by a %T", a, b)
+	}
+	x, xIsInt32 := a.(int32)
+	y, yIsInt32 := b.(int32)
+	if xIsInt32 && yIsInt32 {
+		sum := int64(x) + int64(y)
+		if sum >= math.MinInt32 && sum <= math.MaxInt32 {
+			return int32(sum), nil
+		}
+		return sum, nil
+	}
+	_, aIsFloat := a.(float64)
+	_, bIsFloat := b.(float64)
+	if aIsFloat || bIsFloat {
+		return toFloat(a) + toFloat(b), nil
+	}
+	return int64(toFloat(a)) + int64(toFloat(b)), nil
+}
+
+// applyUpdate runs the update operators on doc. inserting is set for the
+// document an upsert creates.
+func applyUpdate(doc *bson.D, update bson.D, inserting bool) error {
+	if len(update) == 0 {
+		return fmt.Errorf("update document must not be empty")
+	}
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return fmt.Errorf("%s needs a document", op.Key)
+		}
+
+		for _, field := range fields {
+			path := strings.Split(field.Key, ".")
+			for _, key := range path {
+				if strings.HasPrefix(key, "$") {
+					return unsupported("positional updates")
+				}
+			}
+			current, exists := getPath(*doc, path)
+
+			var err error
+			switch op.Key {
+			case "$set":
+				err = setPath(doc, path, clone(field.Value))
+			case "$setOnInsert":
+				if inserting {
+					err = setPath(doc, path, clone(field.Value))
+				}
+			case "$unset":
+				unsetPath(doc, path)
+			case "$inc":
+				value := field.Value
+				if exists {
+					value, err = addNumbers(current, field.Value)
+				}
+				if err == nil {
+					err = setPath(doc, path, value)
+				}
+			case "$min", "$max":
+				c := compare(field.Value, current)
+				if !exists || (op.Key == "$min" && c < 0) || (op.Key == "$max" && c > 0) {
+					err = setPath(doc, path, clone(field.Value))
+				}
+			case "$currentDate":
+				err = setPath(doc, path, primitive.NewDateTimeFromTime(time.Now()))
+			case "$push", "$addToSet":
+				err = pushValues(doc, path, current, exists, field.Value, op.Key == "$addToSet")
+			case "$pull":
+				err = pullValues(doc, path, current, exists, field.Value)
+			default:
+				err = unsupported(op.Key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updater returns the function running update on a document, update being
+// either a document of update operators or a pipeline of stages.
+func updater(update interface{}) (func(doc *bson.D, inserting bool) error, error) {
+	wrapped, err := toDocument(bson.D{{Key: "update", Value: update}})
+	if err != nil {
+		return nil, err
+	}
+	if stages, ok := wrapped[0].Value.(bson.A); ok {
+		return func(doc *bson.D, inserting bool) error {
+			return applyPipeline(doc, stages)
+		}, nil
+	}
+	changes, ok := wrapped[0].Value.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("update must be a document or a pipeline")
+	}
+	return func(doc *bson.D, inserting bool) error {
+		return applyUpdate(doc, changes, inserting)
+	}, nil
+}
+
+// applyPipeline runs the $set and $unset stages of an update pipeline on
+// doc. Their expressions can only be field paths, literals, $add, $round and
+// $ifNull.
+func applyPipeline(doc *bson.D, stages bson.A) error {
+	for _, s := range stages {
+		stage, ok := s.(bson.D)
+		if !ok || len(stage) != 1 {
+			return fmt.Errorf("each stage must be a document with one field")
+		}
+
+		switch stage[0].Key {
+		case "$set", "$addFields":
+			fields, ok := stage[0].Value.(bson.D)
+			if !ok {
+				return fmt.Errorf("%s needs a document", stage[0].Key)
+			}
+			// every expression of the stage sees the document from before it
+			before := clone(*doc).(bson.D)
+			for _, field := range fields {
+				value, err := evaluate(before, field.Value)
+				if err != nil {
+					return err
+				}
+				if err := setPath(doc, strings.Split(field.Key, "."), value); err != nil {
+					return err
+				}
+			}
+		case "$unset":
+			paths := bson.A{stage[0].Value}
+			if array, ok := stage[0].Value.(bson.A); ok {
+				paths = array
+			}
+			for _, p := range paths {
+				path, ok := p.(string)
+				if !ok {
+					return fmt.Errorf("$unset needs field names")
+				}
+				unsetPath(doc, strings.Split(path, "."))
+			}
+		default:
+			return unsupported("the " + stage[0].Key + " update stage")
+		}
+	}
+	return nil
+}
+
+// evaluate works out an aggregation expression against doc.
+func evaluate(doc bson.D, expression interface{}) (interface{}, error) {
+	switch e := expression.(type) {
+	case string:
+		if strings.HasPrefix(e, "$$") {
+			return nil, unsupported("variables")
+		}
+		if strings.HasPrefix(e, "$") {
+			value, _ := getPath(doc, strings.Split(e[1:], "."))
+			return clone(value), nil
+		}
+		return e, nil
+	case bson.A:
+		values := bson.A{}
+		for _, element := range e {
+			value, err := evaluate(doc, element)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case bson.D:
+		if ops, isOps := isOperatorDocument(e); isOps {
+			return evaluateOperator(doc, ops)
+		}
+		fields := bson.D{}
+		for _, field := range e {
+			value, err := evaluate(doc, field.Value)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, bson.E{Key: field.Key, Value: value})
+		}
+		return fields, nil
+	}
+	return clone(expression), nil
+}
+
+func evaluateOperator(doc bson.D, ops bson.D) (interface{}, error) {
+	if len(ops) != 1 {
+		return nil, fmt.Errorf("an expression takes one operator")
+	}
+	op := ops[0]
+	if op.Key == "$literal" {
+		return clone(op.Value), nil
+	}
+
+	evaluated, err := evaluate(doc, op.Value)
+	if err != nil {
+		return nil, err
+	}
+	args, ok := evaluated.(bson.A)
+	if !ok {
+		args = bson.A{evaluated}
+	}
+
+	switch op.Key {
+	case "$add":
+		var sum interface{} = int32(0)
+		for _, arg := range args {
+			if typeOrder(arg) == 1 {
+				return nil, nil
+			}
+			if sum, err = addNumbers(sum, arg); err != nil {
+				return nil, err
+			}
+		}
+		return sum, nil
+	case "$round":
+		if len(args) == 0 || typeOrder(args[0]) == 1 {
+			return nil, nil
+		}
+		value, ok := args[0].(float64)
+		if !ok {
+			// whole numbers are round already
+			return args[0], nil
+		}
+		places := 0.0
+		if len(args) > 1 {
+			places = toFloat(args[1])
+		}
+		scale := math.Pow(10, places)
+		return math.RoundToEven(value*scale) / scale, nil
+	case "$ifNull":
+		for _, arg := range args {
+			if typeOrder(arg) != 1 {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}
+	return nil, unsupported("the " + op.Key + " expression")
+}
+
+func pushValues(doc *bson.D, path []string, current interface{}, exists bool, value interface{}, unique bool) error {
+	array := bson.A{}
+	if exists {
+		existing, ok := current.(bson.A)
+		if !ok {
+			return fmt.Errorf("%s is not an array", strings.Join(path, "."))
+		}
+		array = existing
+	}
+
+	values := bson.A{value}
+	if modifiers, ok := isOperatorDocument(value); ok {
+		each, hasEach := lookupKey(modifiers, "$each")
+		if !hasEach || len(modifiers) != 1 {
+			return unsupported("push modifiers other than $each")
+		}
+		if values, ok = each.(bson.A); !ok {
+			return fmt.Errorf("$each needs an array")
+		}
+	}
+
+	for _, v := range values {
+		if unique && containsValue(array, v) {
+			continue
+		}
+		array = append(array, clone(v))
+	}
+	return setPath(doc, path, array)
+}
+
+func pullValues(doc *bson.D, path []string, current interface{}, exists bool, condition interface{}) error {
+	if !exists {
+		return nil
+	}
+	array, ok := current.(bson.A)
+	if !ok {
+		return fmt.Errorf("%s is not an array", strings.Join(path, "."))
+	}
+
+	kept := bson.A{}
+	for _, element := range array {
+		var matched bool
+		var err error
+		if ops, isOps := isOperatorDocument(condition); isOps {
+			matched, err = matchOperators([]interface{}{element}, ops)
+		} else if filter, isDoc := condition.(bson.D); isDoc {
+			if elementDoc, elementIsDoc := element.(bson.D); elementIsDoc {
+				matched, err = matches(elementDoc, filter)
+			}
+		} else {
+			matched = matchEqual([]interface{}{element}, condition)
+		}
+		if err != nil {
+			return err
+		}
+		if !matched {
+			kept = append(kept, element)
+		}
+	}
+	return setPath(doc, path, kept)
+}
+
+// upsertDocument starts the document an upsert inserts from the equality
+// conditions of the filter.
+func upsertDocument(filter bson.D) (bson.D, error) {
+	doc := bson.D{}
+	for _, e := range filter {
+		if e.Key == "$and" {
+			clauses, _ := e.Value.(bson.A)
+			for _, clause := range clauses {
+				part, _ := clause.(bson.D)
+				seeded, err := upsertDocument(part)
+				if err != nil {
+					return nil, err
+				}
+				for _, field := range seeded {
+					if err := setPath(&doc, strings.Split(field.Key, "."), field.Value); err != nil {
+						return nil, err
+					}
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(e.Key, "$") {
+			continue
+		}
+
+		value := e.Value
+		if ops, isOps := isOperatorDocument(value); isOps {
+			eq, hasEq := lookupKey(ops, "$eq")
+			if !hasEq {
+				continue
+			}
+			value = eq
+		}
+		if err := setPath(&doc, strings.Split(e.Key, "."), clone(value)); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// project applies an inclusion or exclusion projection.
+func project(doc bson.D, projection bson.D) (bson.D, error) {
+	if len(projection) == 0 {
+		return doc, nil
+	}
+
+	include := false
+	for _, e := range projection {
+		if _, isDoc := e.Value.(bson.D); isDoc {
+			return nil, unsupported("projection expressions")
+		}
+		if e.Key != "_id" && truthy(e.Value) {
+			include = true
+		}
+	}
+
+	if !include {
+		projected := clone(doc).(bson.D)
+		for _, e := range projection {
+			if !truthy(e.Value) {
+				unsetPath(&projected, strings.Split(e.Key, "."))
+			}
+		}
+		return projected, nil
+	}
+
+	projected := bson.D{}
+	keepId := true
+	for _, e := range projection {
+		if e.Key == "_id" {
+			keepId = truthy(e.Value)
+			continue
+		}
+		if value, ok := getPath(doc, strings.Split(e.Key, ".")); ok {
+			if err := setPath(&projected, strings.Split(e.Key, "."), clone(value)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if id, ok := lookupKey(doc, "_id"); ok && keepId {
+		projected = append(bson.D{{Key: "_id", Value: id}}, projected...)
+	}
+	return projected, nil
+}
+
+// sortDocuments orders docs by a sort specification, keeping the insertion
+// order of ties.
+func sortDocuments(docs []bson.D, spec bson.D) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, e := range spec {
+			path := strings.Split(e.Key, ".")
+			a, _ := getPath(docs[i], path)
+			b, _ := getPath(docs[j], path)
+			c := compare(a, b)
+			if c == 0 {
+				continue
+			}
+			if toFloat(e.Value) < 0 {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// clone copies a value deeply, stored documents must not share arrays or
+// subdocuments with the callers.
+func clone(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		copied := make(bson.D, len(v))
+		for i, e := range v {
+			copied[i] = bson.E{Key: e.Key, Value: clone(e.Value)}
+		}
+		return copied
+	case bson.A:
+		copied := make(bson.A, len(v))
+		for i, element := range v {
+			copied[i] = clone(element)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/RahulMj21/mongo-restaurant-management/database"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoRepos opens the repositories on db.
+func NewMongoRepos(db *mongo.Database) Repos {
+	open := func(name string) Collection {
+		return mongoCollection{db.Collection(name)}
+	}
+	return newRepos(open, mongoTransactor{db.Client()})
+}
+
+// mongoCollection hands out the driver's cursors and results behind the
+// interfaces of Collection.
+type mongoCollection struct {
+	*mongo.Collection
+}
+
+func (m mongoCollection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (Cursor, error) {
+	cursor, err := m.Collection.Aggregate(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func (m mongoCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (Cursor, error) {
+	cursor, err := m.Collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func (m mongoCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) SingleResult {
+	return m.Collection.FindOne(ctx, filter, opts...)
+}
+
+func (m mongoCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) SingleResult {
+	return m.Collection.FindOneAndUpdate(ctx, filter, update, opts...)
+}
+
+type mongoTransactor struct {
+	client *mongo.Client
+}
+
+func (t mongoTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.WithTransaction(ctx, t.client, func(sessCtx mongo.SessionContext) error {
+		return fn(sessCtx)
+	})
+}
+
+func (t mongoTransactor) SupportsTransactions(ctx context.Context) bool {
+	return database.SupportsTransactions(ctx, t.client)
+}
@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/RahulMj21/mongo-restaurant-management/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection is the part of *mongo.Collection the handlers use. Queries stay
+// written as Mongo filters and updates, so the Mongo repository passes them
+// on to the collection and the in-memory one evaluates the same documents.
+type Collection interface {
+	Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (Cursor, error)
+	CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
+	DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	Distinct(ctx context.Context, fieldName string, filter interface{}, opts ...*options.DistinctOptions) ([]interface{}, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (Cursor, error)
+	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) SingleResult
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) SingleResult
+	InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error)
+	InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+}
+
+// Cursor is what a query returns many documents through, *mongo.Cursor for
+// the Mongo repository.
+type Cursor interface {
+	All(ctx context.Context, results interface{}) error
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// SingleResult is what a query returns one document through,
+// *mongo.SingleResult for the Mongo repository. Err and Decode return
+// mongo.ErrNoDocuments when nothing matched.
+type SingleResult interface {
+	Decode(v interface{}) error
+	Err() error
+}
+
+// Transactor runs writes that belong together. fn must use the context it is
+// given, that is where the session lives.
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// SupportsTransactions reports whether WithTransaction rolls back by
+	// itself. When it does not, fn has to undo its own writes on failure.
+	SupportsTransactions(ctx context.Context) bool
+}
+
+type BundleRepo interface {
+	Collection
+	FindByID(ctx context.Context, bundleId string, bundle *models.Bundle) error
+}
+
+type CounterRepo interface {
+	Collection
+	FindByID(ctx context.Context, key string, counter *models.Counter) error
+}
+
+type CustomerRepo interface {
+	Collection
+	FindByID(ctx context.Context, customerId string, customer *models.Customer) error
+}
+
+type FoodRepo interface {
+	Collection
+	FindByID(ctx context.Context, foodId string, food *models.Food) error
+}
+
+type GiftCardRepo interface {
+	Collection
+	FindByID(ctx context.Context, giftCardId string, giftCard *models.GiftCard) error
+}
+
+type GiftCardTransactionRepo interface {
+	Collection
+	FindByID(ctx context.Context, transactionId string, transaction *models.GiftCardTransaction) error
+}
+
+type ImageRepo interface {
+	Collection
+	FindByID(ctx context.Context, imageId string, image *models.Image) error
+}
+
+type InvoiceRepo interface {
+	Collection
+	FindByID(ctx context.Context, invoiceId string, invoice *models.Invoice) error
+}
+
+type LoyaltyTransactionRepo interface {
+	Collection
+	FindByID(ctx context.Context, transactionId string, transaction *models.LoyaltyTransaction) error
+}
+
+type MenuRepo interface {
+	Collection
+	FindByID(ctx context.Context, menuId string, menu *models.Menu) error
+}
+
+type NoteRepo interface {
+	Collection
+	FindByID(ctx context.Context, noteId string, note *models.Note) error
+}
+
+type OrderRepo interface {
+	Collection
+	FindByID(ctx context.Context, orderId string, order *models.Order) error
+}
+
+type OrderItemRepo interface {
+	Collection
+	FindByID(ctx context.Context, orderItemId string, orderItem *models.OrderItem) error
+}
+
+type TableRepo interface {
+	Collection
+	FindByID(ctx context.Context, tableId string, table *models.Table) error
+}
+
+type UserRepo interface {
+	Collection
+	FindByID(ctx context.Context, userId string, user *models.User) error
+}
+
+// Repos holds one repository per aggregate and the transactions spanning them.
+type Repos struct {
+	Bundles              BundleRepo
+	Counters             CounterRepo
+	Customers            CustomerRepo
+	Foods                FoodRepo
+	GiftCards            GiftCardRepo
+	GiftCardTransactions GiftCardTransactionRepo
+	Images               ImageRepo
+	Invoices             InvoiceRepo
+	LoyaltyTransactions  LoyaltyTransactionRepo
+	Menus                MenuRepo
+	Notes                NoteRepo
+	Orders               OrderRepo
+	OrderItems           OrderItemRepo
+	Tables               TableRepo
+	Users                UserRepo
+	Transactions         Transactor
+}
+
+// Collection names of the aggregates.
+const (
+	bundleCollection              = "bundle"
+	counterCollection             = "counter"
+	customerCollection            = "customer"
+	foodCollection                = "food"
+	giftCardCollection            = "gift_card"
+	giftCardTransactionCollection = "gift_card_transaction"
+	imageCollection               = "image"
+	invoiceCollection             = "invoice"
+	loyaltyTransactionCollection  = "loyalty_transaction"
+	menuCollection                = "menu"
+	noteCollection                = "note"
+	orderCollection               = "order"
+	orderItemCollection           = "order_item"
+	tableCollection               = "table"
+	userCollection                = "user"
+)
+
+// newRepos puts a repository over each collection open returns.
+func newRepos(open func(name string) Collection, transactions Transactor) Repos {
+	return Repos{
+		Bundles:              repo[models.Bundle]{open(bundleCollection), "bundle_id"},
+		Counters:             repo[models.Counter]{open(counterCollection), "_id"},
+		Customers:            repo[models.Customer]{open(customerCollection), "customer_id"},
+		Foods:                repo[models.Food]{open(foodCollection), "food_id"},
+		GiftCards:            repo[models.GiftCard]{open(giftCardCollection), "gift_card_id"},
+		GiftCardTransactions: repo[models.GiftCardTransaction]{open(giftCardTransactionCollection), "transaction_id"},
+		Images:               repo[models.Image]{open(imageCollection), "image_id"},
+		Invoices:             repo[models.Invoice]{open(invoiceCollection), "invoice_id"},
+		LoyaltyTransactions:  repo[models.LoyaltyTransaction]{open(loyaltyTransactionCollection), "transaction_id"},
+		Menus:                repo[models.Menu]{open(menuCollection), "menu_id"},
+		Notes:                repo[models.Note]{open(noteCollection), "note_id"},
+		Orders:               repo[models.Order]{open(orderCollection), "order_id"},
+		OrderItems:           repo[models.OrderItem]{open(orderItemCollection), "order_item_id"},
+		Tables:               repo[models.Table]{open(tableCollection), "table_id"},
+		Users:                repo[models.User]{open(userCollection), "user_id"},
+		Transactions:         transactions,
+	}
+}
+
+// repo looks documents up by the public id every aggregate carries next to
+// its ObjectID.
+type repo[T any] struct {
+	Collection
+	idKey string
+}
+
+// FindByID decodes the document with the given id into doc, or returns
+// mongo.ErrNoDocuments.
+func (r repo[T]) FindByID(ctx context.Context, id string, doc *T) error {
+	return r.FindOne(ctx, bson.D{{Key: r.idKey, Value: id}}).Decode(doc)
+}
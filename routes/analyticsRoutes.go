@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func AnalyticsRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/analytics/orders-by-type", h.GetOrdersByType)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func BundleRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/bundles", h.GetBundles)
+	api.GET("/bundles/:id", h.GetBundle)
+	api.POST("/bundles", h.CreateBundle)
+	api.PATCH("/bundles/:id", h.UpdateBundle)
+}
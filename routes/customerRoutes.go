@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func CustomerRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/customers", h.GetCustomers)
+	api.GET("/customers/:id", h.GetCustomer)
+	api.GET("/customers/:id/visits", h.GetCustomerVisits)
+	api.GET("/customers/:id/loyalty", h.GetCustomerLoyalty)
+	api.POST("/customers", h.CreateCustomer)
+	api.PATCH("/customers/:id", h.UpdateCustomer)
+}
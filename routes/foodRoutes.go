@@ -5,9 +5,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func FoodRoutes(api *gin.RouterGroup) {
-	api.GET("/foods", controllers.GetFoods)
-	api.GET("/foods/:id", controllers.GetFood)
-	api.POST("/foods", controllers.CreateFood)
-	api.PATCH("/foods/:id", controllers.UpdateFood)
+func FoodRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/foods", h.GetFoods)
+	api.GET("/foods/:id", h.GetFood)
+	api.POST("/foods", h.CreateFood)
+	api.PUT("/foods/:id", h.UpdateFood)
+	api.PATCH("/foods/:id", h.UpdateFood)
+	api.DELETE("/foods/:id", h.DeleteFood)
+	api.POST("/foods/:id/restore", h.RestoreFood)
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func GiftCardRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/gift-cards", h.GetGiftCards)
+	api.GET("/gift-cards/:code", h.GetGiftCard)
+	api.GET("/gift-cards/:code/balance", h.GetGiftCardBalance)
+	api.GET("/gift-cards/:code/transactions", h.GetGiftCardTransactions)
+	api.POST("/gift-cards", h.IssueGiftCard)
+	api.POST("/gift-cards/:code/reload", h.ReloadGiftCard)
+}
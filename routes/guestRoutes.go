@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// GuestRoutes are reachable without logging in, the signed table token in
+// the path scopes every request to one table.
+func GuestRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/guest/:token", h.GetGuestTable)
+	api.GET("/guest/:token/menu", h.GetGuestMenu)
+	api.GET("/guest/:token/order", h.GetGuestOrder)
+	api.POST("/guest/:token/order-items", h.CreateGuestOrderItems)
+}
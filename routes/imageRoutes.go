@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func ImageRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/images/:id", h.GetImage)
+	api.POST("/images", h.UploadImage)
+	api.DELETE("/images/:id", h.DeleteImage)
+}
+
+// ImageContentRoutes serve the image bytes without logging in, guests see
+// the food pictures on the menu behind the table's QR code.
+func ImageContentRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/images/:id/content", h.GetImageContent)
+	api.GET("/images/:id/thumbnail", h.GetImageThumbnail)
+}
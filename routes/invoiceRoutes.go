@@ -5,10 +5,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func InvoiceRoutes(api *gin.RouterGroup) {
-	api.GET("/invoices", controllers.GetInvoices)
-	api.GET("/invoices/:id", controllers.GetInvoice)
-	api.POST("/invoices", controllers.CreateInvoice)
-	api.PATCH("/invoices/:id", controllers.UpdateInvoice)
+func InvoiceRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/invoices", h.GetInvoices)
+	api.GET("/invoices/:id", h.GetInvoice)
+	api.POST("/invoices", h.CreateInvoice)
+	api.POST("/invoices/split-by-seat", h.SplitInvoiceBySeat)
+	api.PUT("/invoices/:id", h.UpdateInvoice)
+	api.PATCH("/invoices/:id", h.UpdateInvoice)
+	api.DELETE("/invoices/:id", h.DeleteInvoice)
+	api.POST("/invoices/:id/restore", h.RestoreInvoice)
+	api.POST("/invoices/:id/payments", h.AddInvoicePayment)
 
 }
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func KitchenRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/kitchen/queue", h.GetKitchenQueue)
+}
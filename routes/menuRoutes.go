@@ -5,9 +5,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func MenuRoutes(api *gin.RouterGroup) {
-	api.GET("/menus", controllers.GetMenus)
-	api.GET("/menus/:id", controllers.GetMenu)
-	api.POST("/menus", controllers.CreateMenu)
-	api.PATCH("/menus/:id", controllers.UpdateMenu)
+func MenuRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/menus", h.GetMenus)
+	api.GET("/menus/:id", h.GetMenu)
+	api.POST("/menus", h.CreateMenu)
+	api.PUT("/menus/:id", h.UpdateMenu)
+	api.PATCH("/menus/:id", h.UpdateMenu)
+	api.DELETE("/menus/:id", h.DeleteMenu)
+	api.POST("/menus/:id/restore", h.RestoreMenu)
+	api.GET("/menus/:id/foods", h.GetMenuFoods)
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func NoteRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/notes", h.GetNotes)
+	api.GET("/notes/:id", h.GetNote)
+	api.POST("/notes", h.CreateNote)
+	api.PATCH("/notes/:id", h.UpdateNote)
+	api.DELETE("/notes/:id", h.DeleteNote)
+}
@@ -5,10 +5,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func OrderItemRoutes(api *gin.RouterGroup) {
-	api.GET("/order-items", controllers.GetOrderItems)
-	api.GET("/order-items/:id", controllers.GetOrderItem)
-	api.GET("/order-items-order/:order_id", controllers.GetOrderItemsByOrderId)
-	api.POST("/order-items", controllers.CreateOrderItem)
-	api.PATCH("/order-items/:id", controllers.UpdateOrderItem)
+func OrderItemRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/order-items", h.GetOrderItems)
+	api.GET("/order-items/:id", h.GetOrderItem)
+	api.GET("/order-items-order/:order_id", h.GetOrderItemsByOrderId)
+	api.POST("/order-items", h.CreateOrderItem)
+	api.PUT("/order-items/:id", h.UpdateOrderItem)
+	api.PATCH("/order-items/:id", h.UpdateOrderItem)
+	api.GET("/order-items-pending", h.GetPendingOrderItems)
+	api.POST("/order-items/:id/approve", h.ApproveOrderItem)
+	api.POST("/order-items/:id/reject", h.RejectOrderItem)
 }
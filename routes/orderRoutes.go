@@ -5,9 +5,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func OrderRoutes(api *gin.RouterGroup) {
-	api.GET("/orders", controllers.GetOrders)
-	api.GET("/orders/:id", controllers.GetOrder)
-	api.POST("/orders", controllers.CreateOrder)
-	api.PATCH("/orders/:id", controllers.UpdateOrder)
+func OrderRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/orders", h.GetOrders)
+	api.GET("/orders/:id", h.GetOrder)
+	api.POST("/orders", h.CreateOrder)
+	api.PUT("/orders/:id", h.UpdateOrder)
+	api.PATCH("/orders/:id", h.UpdateOrder)
+	api.DELETE("/orders/:id", h.DeleteOrder)
+	api.POST("/orders/:id/restore", h.RestoreOrder)
+	api.POST("/orders/:id/driver", h.AssignOrderDriver)
+	api.GET("/orders/:id/courses", h.GetOrderCourses)
+	api.POST("/orders/:id/courses/:course/fire", h.FireOrderCourse)
+	api.POST("/orders/:id/courses/:course/hold", h.HoldOrderCourse)
 }
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func SearchRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/search", h.Search)
+	api.GET("/search/suggestions", h.SearchSuggestions)
+}
@@ -5,9 +5,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func TableRoutes(api *gin.RouterGroup) {
-	api.GET("/tables", controllers.GetTables)
-	api.GET("/tables/:id", controllers.GetTable)
-	api.POST("/tables", controllers.CreateTable)
-	api.PATCH("/tables/:id", controllers.UpdateTable)
+func TableRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/tables", h.GetTables)
+	api.GET("/tables/:id", h.GetTable)
+	api.POST("/tables", h.CreateTable)
+	api.PUT("/tables/:id", h.UpdateTable)
+	api.PATCH("/tables/:id", h.UpdateTable)
+	api.DELETE("/tables/:id", h.DeleteTable)
+	api.POST("/tables/:id/restore", h.RestoreTable)
+	api.GET("/tables/:id/qr", h.GetTableQRCode)
+	api.POST("/tables/:id/qr/rotate", h.RotateTableLink)
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/RahulMj21/mongo-restaurant-management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func TranslationRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/translations/missing", h.GetMissingTranslations)
+	api.PUT("/menus/:id/translations/:lang", h.PutMenuTranslation)
+	api.DELETE("/menus/:id/translations/:lang", h.DeleteMenuTranslation)
+	api.PUT("/foods/:id/translations/:lang", h.PutFoodTranslation)
+	api.DELETE("/foods/:id/translations/:lang", h.DeleteFoodTranslation)
+}
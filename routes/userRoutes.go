@@ -5,9 +5,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(api *gin.RouterGroup) {
-	api.GET("/users", controllers.GetUsers)
-	api.GET("/users/:id", controllers.GetUser)
-	api.GET("/signup", controllers.SignUp)
-	api.GET("/login", controllers.Login)
+func UserRoutes(api *gin.RouterGroup, h *controllers.Handler) {
+	api.GET("/users", h.GetUsers)
+	api.GET("/users/:id", h.GetUser)
+	api.GET("/signup", h.SignUp)
+	api.GET("/login", h.Login)
 }
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned for keys the store holds no blob for.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps blobs under the sha256 of their content, so storing the
+// same bytes twice stores them once and a key never changes its content.
+type BlobStore interface {
+	// Put stores data under key, which must be ContentKey(data). Putting a
+	// key that is already stored is not an error.
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the blob, deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ContentKey is the key data is stored under.
+func ContentKey(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FromEnv opens the store IMAGE_STORE names: "local" (the default) keeps
+// blobs below IMAGE_STORE_PATH, "gridfs" keeps them in the database's
+// IMAGE_STORE_BUCKET GridFS bucket.
+func FromEnv(db *mongo.Database) (BlobStore, error) {
+	switch os.Getenv("IMAGE_STORE") {
+	case "", "local":
+		root := os.Getenv("IMAGE_STORE_PATH")
+		if root == "" {
+			root = "uploads"
+		}
+		return NewLocalStore(root)
+	case "gridfs":
+		bucket := os.Getenv("IMAGE_STORE_BUCKET")
+		if bucket == "" {
+			bucket = "images"
+		}
+		return NewGridFSStore(db, bucket)
+	default:
+		return nil, fmt.Errorf("unknown IMAGE_STORE %s", os.Getenv("IMAGE_STORE"))
+	}
+}
+
+func checkKey(key string) error {
+	if len(key) != sha256.Size*2 {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	if _, err := hex.DecodeString(key); err != nil {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
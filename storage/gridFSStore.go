@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFSStore keeps blobs in a GridFS bucket, using the key as the file id
+// so a blob can only be stored once.
+type GridFSStore struct {
+	bucket *gridfs.Bucket
+}
+
+func NewGridFSStore(db *mongo.Database, bucketName string) (*GridFSStore, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return nil, err
+	}
+	return &GridFSStore{bucket: bucket}, nil
+}
+
+func (s *GridFSStore) Put(ctx context.Context, key string, data []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if key != ContentKey(data) {
+		return fmt.Errorf("blob key %s does not match its content", key)
+	}
+
+	exists, err := s.Exists(ctx, key)
+	if err != nil || exists {
+		return err
+	}
+	err = s.bucket.UploadFromStreamWithID(key, key, bytes.NewReader(data))
+	if mongo.IsDuplicateKeyError(err) {
+		// stored by a concurrent upload of the same content
+		return nil
+	}
+	return err
+}
+
+func (s *GridFSStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	if _, err := s.bucket.DownloadToStream(key, &data); err != nil {
+		if err == gridfs.ErrFileNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+func (s *GridFSStore) Exists(ctx context.Context, key string) (bool, error) {
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	count, err := s.bucket.GetFilesCollection().CountDocuments(ctx, bson.D{{Key: "_id", Value: key}})
+	return count > 0, err
+}
+
+func (s *GridFSStore) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	err := s.bucket.DeleteContext(ctx, key)
+	if err == gridfs.ErrFileNotFound {
+		return nil
+	}
+	return err
+}